@@ -0,0 +1,127 @@
+// Command encrypt-tokens encrypts OAuth tokens that are still stored as plaintext
+// and re-encrypts tokens protected by a key version other than the active one.
+//
+// Run it after enabling token encryption and after every key rotation:
+//
+//	go run ./cmd/encrypt-tokens
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"google-calendar-api/models"
+	"google-calendar-api/utils"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchSize is the number of identities processed per query.
+const batchSize = 100
+
+func main() {
+	// Load environment variables from .env file
+	if err := godotenv.Load(); err != nil {
+		log.Fatal("❌ Error loading .env file:", err)
+	}
+
+	keyring, err := utils.LoadKeyringFromEnv()
+	if err != nil {
+		log.Fatal("❌ Token encryption is not configured:", err)
+	}
+	utils.SetKeyring(keyring)
+
+	dsn := os.Getenv("DB_URL")
+	if dsn == "" {
+		log.Fatal("❌ Database URL not provided in environment variables")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatal("❌ Failed to connect to database:", err)
+	}
+
+	// Copy tokens still stored on the users table onto identities first
+	if _, err := models.MigrateLegacyIdentities(db); err != nil {
+		log.Fatal("❌ Failed to migrate legacy identities:", err)
+	}
+
+	updated := 0
+	var lastID uuid.UUID
+	for {
+		var ids []uuid.UUID
+		err := db.Model(&models.Identity{}).
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(batchSize).
+			Pluck("id", &ids).Error
+		if err != nil {
+			log.Fatal("❌ Failed to read identities:", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		for _, id := range ids {
+			lastID = id
+			changed, err := reencrypt(db, keyring, id)
+			if err != nil {
+				log.Fatalf("❌ Failed to re-encrypt tokens of identity %s: %v", id, err)
+			}
+			if changed {
+				updated++
+			}
+		}
+	}
+
+	log.Printf("✅ Encrypted tokens for %d identities", updated)
+}
+
+// reencrypt encrypts the identity's tokens with the active key if they are plaintext
+// or use another key version. The row is locked like in tokens.Manager, so a token
+// refreshed by the server meanwhile is never overwritten with the value read here.
+func reencrypt(db *gorm.DB, keyring *utils.Keyring, id uuid.UUID) (bool, error) {
+	// Read the raw column values so plaintext and stale ciphertext can be told apart
+	var r struct {
+		AccessToken  string
+		RefreshToken string
+	}
+
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Identity{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("access_token, refresh_token").
+			Where("id = ?", id).
+			Take(&r).Error
+		if err != nil {
+			return err
+		}
+		if !keyring.NeedsReencryption(r.AccessToken) && !keyring.NeedsReencryption(r.RefreshToken) {
+			return nil
+		}
+
+		accessToken, err := keyring.Decrypt(r.AccessToken)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt access token: %w", err)
+		}
+		refreshToken, err := keyring.Decrypt(r.RefreshToken)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt refresh token: %w", err)
+		}
+
+		// EncryptedString encrypts with the active key on write
+		err = tx.Model(&models.Identity{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"access_token":  models.EncryptedString(accessToken),
+			"refresh_token": models.EncryptedString(refreshToken),
+		}).Error
+		changed = err == nil
+		return err
+	})
+	return changed, err
+}
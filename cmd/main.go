@@ -3,6 +3,7 @@ package main
 import (
 	"google-calendar-api/cmd/server"
 	"google-calendar-api/models"
+	"google-calendar-api/utils"
 	"log"
 	"os"
 
@@ -23,6 +24,13 @@ func main() {
 		log.Fatal("❌ Database URL not provided in environment variables")
 	}
 
+	// Load the keys that encrypt OAuth tokens at rest
+	keyring, err := utils.LoadKeyringFromEnv()
+	if err != nil {
+		log.Fatal("❌ Token encryption is not configured:", err)
+	}
+	utils.SetKeyring(keyring)
+
 	// Initialize PostgreSQL database connection
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
@@ -31,13 +39,23 @@ func main() {
 	log.Println("✅ Connected to database")
 
 	// Run database migrations for required models
-	if err := db.AutoMigrate(&models.User{}, &models.Meeting{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Identity{}, &models.Meeting{}, &models.Session{}, &models.DeniedSignIn{}, &models.PersonalAccessToken{}, &models.AuditEvent{}, &models.RateLimitBucket{}); err != nil {
 		log.Fatal("❌ Migration failed:", err)
 	}
+
+	// Users created before account linking keep their Google account as their first identity
+	if n, err := models.MigrateLegacyIdentities(db); err != nil {
+		log.Fatal("❌ Failed to migrate legacy identities:", err)
+	} else if n > 0 {
+		log.Printf("✅ Created identities for %d existing users", n)
+	}
 	log.Println("✅ Database migration completed")
 
 	// Initialize and start the HTTP server
-	srv := server.NewServer(db)
+	srv, err := server.NewServer(db)
+	if err != nil {
+		log.Fatal("❌ Failed to initialize server:", err)
+	}
 	log.Println("🚀 Server is running on port 8080")
 	if err := srv.Run(":8080"); err != nil {
 		log.Fatalf("❌ Failed to start server: %v", err)
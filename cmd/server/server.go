@@ -1,9 +1,12 @@
 package server
 
 import (
+	"context"
 	"net/http"
 
 	"google-calendar-api/internal/handler"
+	"google-calendar-api/internal/refresher"
+	"google-calendar-api/utils"
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
@@ -11,40 +14,126 @@ import (
 
 // Server struct represents the application server with a router.
 type Server struct {
-	router *mux.Router
+	router    *mux.Router
+	refresher *refresher.Refresher // Background token refresher, nil when disabled
 }
 
 // NewServer initializes a new Server instance and sets up the routes.
-func NewServer(db *gorm.DB) *Server {
+// Unless TOKEN_REFRESHER_ENABLED is false, it also sets up the background
+// token refresher that Run starts alongside the HTTP server.
+func NewServer(db *gorm.DB) (*Server, error) {
+	h, err := handler.NewHandler(db)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &Server{
 		router: mux.NewRouter(),
 	}
-	s.setupRoutes(db)
-	return s
+	s.setupRoutes(h)
+
+	if utils.GetEnvBool("TOKEN_REFRESHER_ENABLED", true) {
+		s.refresher = refresher.New(db, h.Tokens(), refresher.ConfigFromEnv(), nil)
+	}
+	return s, nil
 }
 
-// setupRoutes configures all the API routes and assigns them to the router.
-func (s *Server) setupRoutes(db *gorm.DB) {
-	h := handler.NewHandler(db)
+// apiRoutePermissions maps the names of the /api routes to the permission they require.
+// Routes missing from this map are denied.
+var apiRoutePermissions = map[string]handler.Permission{
+	"me":                  handler.PermProfile,
+	"dashboard":           handler.PermAccount,
+	"events.create":       handler.PermEventsWrite,
+	"events.list":         handler.PermEventsRead,
+	"events.get":          handler.PermEventsRead,
+	"events.update":       handler.PermEventsWrite,
+	"events.delete":       handler.PermEventsWrite,
+	"calendars.list":      handler.PermEventsRead,
+	"calendars.default":   handler.PermAccount,
+	"sessions.list":       handler.PermAccount,
+	"sessions.revoke_all": handler.PermAccount,
+	"sessions.revoke":     handler.PermAccount,
+	"identities.list":     handler.PermAccount,
+	"identities.unlink":   handler.PermAccount,
+	"account.disconnect":  handler.PermAccount,
+	"tokens.create":       handler.PermAccount,
+	"tokens.list":         handler.PermAccount,
+	"tokens.revoke":       handler.PermAccount,
+	"admin.users.list":    handler.PermUsersManage,
+	"admin.users.role":    handler.PermUsersManage,
+	"admin.audit":         handler.PermAuditRead,
+}
 
+// setupRoutes configures all the API routes and assigns them to the router.
+func (s *Server) setupRoutes(h *handler.Handler) {
 	// Authentication routes
 	s.router.HandleFunc("/login", h.LoginPage).Methods("GET")
-	s.router.HandleFunc("/auth/google/login", h.GoogleLogin).Methods("GET")
-	s.router.HandleFunc("/auth/google/callback", h.GoogleCallback).Methods("GET")
 
-	// Protected API routes (require authentication)
+	// Sign-in, callbacks and device authorization, rate limited per client IP
+	auth := s.router.PathPrefix("/auth").Subrouter()
+	auth.Use(h.RateLimit("auth"))
+
+	auth.Handle("/google/authorize", h.AuthMiddleware(http.HandlerFunc(h.GoogleAuthorize))).Methods("GET") // Grant calendar scopes
+	auth.Handle("/{provider}/link", h.AuthMiddleware(http.HandlerFunc(h.LinkIdentity))).Methods("GET")     // Link another account
+	auth.HandleFunc("/{provider}/login", h.Login).Methods("GET")
+	auth.HandleFunc("/{provider}/callback", h.Callback).Methods("GET")
+
+	// Device authorization for command-line clients
+	auth.HandleFunc("/device/code", h.DeviceCode).Methods("POST")                                       // Request device and user codes
+	auth.HandleFunc("/device/token", h.DeviceToken).Methods("POST")                                     // Poll for the session token
+	s.router.Handle("/device", h.RateLimit("auth")(http.HandlerFunc(h.DevicePage))).Methods("GET")      // Enter and confirm a user code
+	s.router.Handle("/device", h.RateLimit("auth")(http.HandlerFunc(h.DeviceDecision))).Methods("POST") // Approve or deny a user code
+
+	// Protected API routes (require authentication and a role granting the route's permission)
 	api := s.router.PathPrefix("/api").Subrouter()
-	api.Use(h.AuthMiddleware) // Apply authentication middleware
+	api.Use(h.AuthMiddleware)                          // Apply authentication middleware
+	api.Use(h.RateLimit("api"))                        // Throttle each user's API calls
+	api.Use(h.RequirePermissions(apiRoutePermissions)) // Enforce role permissions by route name
 
-	api.HandleFunc("/dashboard", h.Dashboard).Methods("GET") // Dashboard route
-	api.HandleFunc("/events/create", h.CreateEvent).Methods("POST") // Create event
-	api.HandleFunc("/events/list", h.ListEvents).Methods("GET") // List events
+	api.HandleFunc("/me", h.Me).Methods("GET").Name("me")                      // Current user, scopes and connection status
+	api.HandleFunc("/dashboard", h.Dashboard).Methods("GET").Name("dashboard") // Dashboard route
+
+	// Event routes call the Google Calendar API and share its quota, so they have a stricter limit
+	events := api.PathPrefix("/events").Subrouter()
+	events.Use(h.RateLimit("calendar"))
+
+	events.HandleFunc("/create", h.CreateEvent).Methods("POST").Name("events.create") // Create event
+	events.HandleFunc("/list", h.ListEvents).Methods("GET").Name("events.list")       // List events
+	events.HandleFunc("/{id}", h.GetEvent).Methods("GET").Name("events.get")          // Get one event
+	events.HandleFunc("/{id}", h.UpdateEvent).Methods("PATCH").Name("events.update")  // Update an event
+	events.HandleFunc("/{id}", h.DeleteEvent).Methods("DELETE").Name("events.delete") // Delete an event
+
+	calendars := api.PathPrefix("/calendars").Subrouter()
+	calendars.Use(h.RateLimit("calendar"))
+
+	calendars.HandleFunc("", h.ListCalendars).Methods("GET").Name("calendars.list")                 // List calendars of all linked accounts
+	calendars.HandleFunc("/default", h.SetDefaultCalendar).Methods("PUT").Name("calendars.default") // Choose the default calendar
+
+	api.HandleFunc("/sessions", h.ListSessions).Methods("GET").Name("sessions.list")               // List active sessions
+	api.HandleFunc("/sessions", h.RevokeAllSessions).Methods("DELETE").Name("sessions.revoke_all") // Log out everywhere
+	api.HandleFunc("/sessions/{id}", h.RevokeSession).Methods("DELETE").Name("sessions.revoke")    // Revoke one session
+
+	api.HandleFunc("/identities", h.ListIdentities).Methods("GET").Name("identities.list")           // List linked accounts
+	api.HandleFunc("/identities/{id}", h.UnlinkIdentity).Methods("DELETE").Name("identities.unlink") // Unlink an account
+
+	api.HandleFunc("/account/disconnect", h.DisconnectAccounts).Methods("POST").Name("account.disconnect") // Revoke access of all linked accounts and log out
+
+	api.HandleFunc("/tokens", h.CreatePersonalAccessToken).Methods("POST").Name("tokens.create")        // Create a personal access token
+	api.HandleFunc("/tokens", h.ListPersonalAccessTokens).Methods("GET").Name("tokens.list")            // List personal access tokens
+	api.HandleFunc("/tokens/{id}", h.RevokePersonalAccessToken).Methods("DELETE").Name("tokens.revoke") // Revoke a personal access token
+
+	api.HandleFunc("/admin/users", h.ListUsers).Methods("GET").Name("admin.users.list")                // List users and roles
+	api.HandleFunc("/admin/users/{id}/role", h.UpdateUserRole).Methods("PUT").Name("admin.users.role") // Change a user's role
+	api.HandleFunc("/admin/audit", h.ListAuditEvents).Methods("GET").Name("admin.audit")               // Query and export the audit log
 
 	// Logout route
-	s.router.HandleFunc("/logout", h.Logout)
+	s.router.HandleFunc("/logout", h.Logout).Methods("POST")
 }
 
-// Run starts the HTTP server on the specified address.
+// Run starts the background jobs and the HTTP server on the specified address.
 func (s *Server) Run(addr string) error {
+	if s.refresher != nil {
+		go s.refresher.Run(context.Background())
+	}
 	return http.ListenAndServe(addr, s.router)
 }
@@ -0,0 +1,93 @@
+// Package access decides which accounts may sign in.
+package access
+
+import (
+	"fmt"
+	"strings"
+
+	"google-calendar-api/internal/provider"
+	"google-calendar-api/utils"
+)
+
+// Reasons a sign-in is denied.
+const (
+	ReasonEmailUnverified = "email_unverified" // The provider does not vouch for the email address
+	ReasonEmailDenied     = "email_denied"     // The email address is explicitly denied
+	ReasonNotAllowed      = "not_allowed"      // Neither the hosted domain nor the email address is allowed
+)
+
+// Denial is returned by Policy.Check when an account may not sign in.
+type Denial struct {
+	Reason string // One of the Reason constants
+	Detail string // Human-readable explanation for logs and the audit record
+}
+
+func (d *Denial) Error() string {
+	return fmt.Sprintf("sign-in denied (%s): %s", d.Reason, d.Detail)
+}
+
+// Policy holds the allow and deny rules applied to every sign-in.
+//
+// An account is allowed if its email is verified, it is not on the deny list and,
+// when any allowlist is configured, either its hosted domain or its email address
+// is allowed. With no allowlists configured every verified account is allowed.
+type Policy struct {
+	RequireVerifiedEmail bool                // Reject accounts whose email_verified claim is false
+	AllowedDomains       map[string]struct{} // Google Workspace domains ("hd" claim) that may sign in
+	AllowedEmails        map[string]struct{} // Individual addresses that may sign in regardless of domain
+	DeniedEmails         map[string]struct{} // Addresses that may never sign in
+}
+
+// PolicyFromEnv reads the policy from the environment:
+//   - REQUIRE_VERIFIED_EMAIL (default true)
+//   - ALLOWED_HOSTED_DOMAINS: comma-separated Workspace domains
+//   - ALLOWED_EMAILS: comma-separated email addresses
+//   - DENIED_EMAILS: comma-separated email addresses
+func PolicyFromEnv() *Policy {
+	return &Policy{
+		RequireVerifiedEmail: utils.GetEnvBool("REQUIRE_VERIFIED_EMAIL", true),
+		AllowedDomains:       parseList(utils.GetEnv("ALLOWED_HOSTED_DOMAINS", "")),
+		AllowedEmails:        parseList(utils.GetEnv("ALLOWED_EMAILS", "")),
+		DeniedEmails:         parseList(utils.GetEnv("DENIED_EMAILS", "")),
+	}
+}
+
+// Check returns a *Denial if the profile may not sign in, or nil if it may.
+// The hosted domain comes from the verified "hd" claim and is never derived from
+// the email address, which a personal account can choose freely.
+func (p *Policy) Check(profile provider.Profile) error {
+	email := strings.ToLower(profile.Email)
+	domain := strings.ToLower(profile.HostedDomain)
+
+	if p.RequireVerifiedEmail && !profile.EmailVerified {
+		return &Denial{Reason: ReasonEmailUnverified, Detail: "email " + email + " is not verified"}
+	}
+	if _, denied := p.DeniedEmails[email]; denied {
+		return &Denial{Reason: ReasonEmailDenied, Detail: "email " + email + " is denied"}
+	}
+	if len(p.AllowedDomains) == 0 && len(p.AllowedEmails) == 0 {
+		return nil
+	}
+	if _, ok := p.AllowedEmails[email]; ok {
+		return nil
+	}
+	if _, ok := p.AllowedDomains[domain]; ok && domain != "" {
+		return nil
+	}
+	if domain == "" {
+		return &Denial{Reason: ReasonNotAllowed, Detail: "email " + email + " has no hosted domain and is not allowed"}
+	}
+	return &Denial{Reason: ReasonNotAllowed, Detail: "hosted domain " + domain + " is not allowed"}
+}
+
+// parseList splits a comma-separated list into a lowercase set.
+func parseList(value string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			set[item] = struct{}{}
+		}
+	}
+	return set
+}
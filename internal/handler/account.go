@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/models"
+)
+
+// revocationClient calls the providers' revocation endpoints.
+var revocationClient = &http.Client{Timeout: 10 * time.Second}
+
+// DisconnectAccounts revokes the grants of every account linked to the user at their
+// identity providers, wipes the stored OAuth tokens and logs the user out of every
+// session. Providers without a revocation endpoint only have their tokens wiped.
+// The linked accounts themselves are kept so the user can sign in again.
+//
+// Each account's tokens are wiped as soon as its own grant is revoked. If some
+// revocations fail, the others still take effect, the user stays signed in to retry
+// and the failed accounts are listed in the 502 response.
+func (h *Handler) DisconnectAccounts(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	var identities []models.Identity
+	if err := h.DB.Where("user_id = ?", userID).Find(&identities).Error; err != nil {
+		log.Println("❌ Failed to retrieve identities from DB:", err)
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	failed := []identityView{}
+	for i := range identities {
+		identity := &identities[i]
+		if err := h.revokeIdentity(r, identity); err != nil {
+			log.Printf("❌ Failed to revoke grant of %s: %v", identity.Email, err)
+			h.recordAudit(r, audit.Entry{Action: audit.ActionAccountDisconnect, Target: identity.ID.String(), Outcome: audit.Failure, Detail: err.Error()})
+			failed = append(failed, newIdentityView(*identity))
+			continue
+		}
+
+		err := h.DB.Model(identity).Updates(map[string]interface{}{
+			"access_token":   models.EncryptedString(""),
+			"refresh_token":  models.EncryptedString(""),
+			"expires_at":     time.Time{},
+			"granted_scopes": "",
+		}).Error
+		if err != nil {
+			log.Println("❌ Failed to clear stored tokens:", err)
+			h.recordAudit(r, audit.Entry{Action: audit.ActionAccountDisconnect, Target: identity.ID.String(), Outcome: audit.Failure, Detail: err.Error()})
+			failed = append(failed, newIdentityView(*identity))
+		}
+	}
+	if len(failed) > 0 {
+		writeJSONError(w, http.StatusBadGateway, apiError{
+			Error:          "revocation_failed",
+			Message:        "Access could not be revoked for some accounts, please try again.",
+			FailedAccounts: failed,
+		})
+		return
+	}
+
+	if err := h.sessions.RevokeAll(r.Context(), userID); err != nil {
+		log.Println("❌ Failed to revoke sessions:", err)
+	}
+	clearSessionCookie(w)
+
+	h.recordAudit(r, audit.Entry{Action: audit.ActionAccountDisconnect, Target: userID.String(), Outcome: audit.Success})
+	log.Println("✅ Account disconnected for user", userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "All linked accounts disconnected"})
+}
+
+// revokeToken calls the provider's revocation endpoint for the given token.
+// A token that was already revoked or has expired is treated as success.
+func (h *Handler) revokeToken(ctx context.Context, revocationURL, token string) error {
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revocationURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := revocationClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var oauthErr struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error == "invalid_token" {
+		log.Println("🔹 Grant was already revoked")
+		return nil
+	}
+
+	return fmt.Errorf("revocation endpoint returned %s: %s", resp.Status, body)
+}
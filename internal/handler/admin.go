@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errLastAdmin is returned when a role change would leave no administrator.
+var errLastAdmin = errors.New("cannot remove the last administrator")
+
+// ListUsers returns every user with their role.
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	var users []models.User
+	if err := h.DB.Order("created_at").Find(&users).Error; err != nil {
+		log.Println("❌ Failed to list users:", err)
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"users": users})
+}
+
+// UpdateUserRole changes a user's role. The last administrator cannot be demoted.
+func (h *Handler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	var request struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !models.ValidRole(request.Role) {
+		writeJSONError(w, http.StatusBadRequest, apiError{
+			Error:   "invalid_role",
+			Message: "Role must be one of admin, member or viewer.",
+		})
+		return
+	}
+
+	var user models.User
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the administrators so concurrent demotions cannot remove the last two at once
+		var admins []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("role = ?", models.RoleAdmin).Find(&admins).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", id).First(&user).Error; err != nil {
+			return err
+		}
+		if user.Role == models.RoleAdmin && request.Role != models.RoleAdmin && len(admins) <= 1 {
+			return errLastAdmin
+		}
+		user.Role = request.Role
+		return tx.Model(&user).Update("role", request.Role).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errLastAdmin):
+		writeJSONError(w, http.StatusConflict, apiError{
+			Error:   "last_admin",
+			Message: "At least one administrator must remain.",
+		})
+		return
+	case err != nil:
+		log.Println("❌ Failed to update user role:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(r, audit.Entry{Action: audit.ActionRoleUpdate, Target: user.ID.String(), Outcome: audit.Success, Detail: user.Role})
+	log.Printf("✅ Role of user %s changed to %s", user.ID, user.Role)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+)
+
+// Limits on the number of audit events returned by ListAuditEvents.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 10000
+)
+
+// recordAudit writes an audit event for the request, filling in the client's IP
+// address and user agent, and the signed-in user as actor unless one is given.
+func (h *Handler) recordAudit(r *http.Request, e audit.Entry) {
+	if current, ok := CurrentUserFrom(r.Context()); ok && e.ActorID == uuid.Nil {
+		e.ActorID = current.ID()
+		if e.ActorEmail == "" {
+			e.ActorEmail = current.User.Email
+		}
+	}
+	e.IP = clientIP(r)
+	e.UserAgent = r.UserAgent()
+	h.audit.Record(r.Context(), e)
+}
+
+// ListAuditEvents returns audit events, newest first, for administrators.
+//
+// Query parameters (all optional):
+//   - actor: user ID or email address
+//   - action, outcome, target: exact matches
+//   - since, until: RFC 3339 timestamps bounding created_at
+//   - limit: maximum number of events (default 100, at most 10000)
+//   - format: "json" (default) or "csv" for a download
+func (h *Handler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	db := h.DB.Model(&models.AuditEvent{})
+
+	if actor := query.Get("actor"); actor != "" {
+		if id, err := uuid.Parse(actor); err == nil {
+			db = db.Where("actor_id = ?", id)
+		} else {
+			db = db.Where("actor_email = ?", actor)
+		}
+	}
+	for _, column := range []string{"action", "outcome", "target"} {
+		if value := query.Get(column); value != "" {
+			db = db.Where(column+" = ?", value)
+		}
+	}
+	for param, condition := range map[string]string{"since": "created_at >= ?", "until": "created_at < ?"} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, apiError{
+				Error:   "invalid_request",
+				Message: param + " must be an RFC 3339 timestamp.",
+			})
+			return
+		}
+		db = db.Where(condition, t)
+	}
+
+	limit := defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, apiError{
+				Error:   "invalid_request",
+				Message: "limit must be a positive number.",
+			})
+			return
+		}
+		if n < maxAuditLimit {
+			limit = n
+		} else {
+			limit = maxAuditLimit
+		}
+	}
+
+	var events []models.AuditEvent
+	if err := db.Order("created_at DESC").Limit(limit).Find(&events).Error; err != nil {
+		log.Println("❌ Failed to query audit events:", err)
+		http.Error(w, "Failed to query audit events", http.StatusInternalServerError)
+		return
+	}
+
+	if query.Get("format") == "csv" {
+		writeAuditCSV(w, events)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+}
+
+// writeAuditCSV writes the events as a CSV download.
+func writeAuditCSV(w http.ResponseWriter, events []models.AuditEvent) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-events.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "actor_id", "actor_email", "action", "target", "outcome", "detail", "ip", "user_agent"})
+	for _, e := range events {
+		actorID := ""
+		if e.ActorID != nil {
+			actorID = e.ActorID.String()
+		}
+		out.Write([]string{
+			e.ID.String(),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			actorID,
+			csvSafe(e.ActorEmail),
+			e.Action,
+			csvSafe(e.Target),
+			e.Outcome,
+			csvSafe(e.Detail),
+			e.IP,
+			csvSafe(e.UserAgent),
+		})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Println("❌ Failed to write audit CSV:", err)
+	}
+}
+
+// csvSafe prefixes values that spreadsheets would evaluate as formulas, since
+// user agents and emails in the audit log are supplied by clients.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -3,17 +3,25 @@ package handler
 import (
 	// "go/token"
 
+	"crypto/subtle"
 	"errors"
 	"html/template"
 	"log"
 	"net/http"
 	"path/filepath"
-	"time"
+	"strings"
 
+	"google-calendar-api/internal/access"
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/internal/provider"
 	"google-calendar-api/models"
+	"google-calendar-api/utils"
 
-	"github.com/coreos/go-oidc"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"golang.org/x/oauth2"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
 	"gorm.io/gorm"
 )
 
@@ -26,9 +34,11 @@ func (h *Handler) LoginPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := struct {
-		Message string
+		Message   string
+		Providers []*provider.Provider
 	}{
-		Message: "Please log in to access your dashboard.",
+		Message:   "Please log in to access your dashboard.",
+		Providers: h.providers.All(),
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -38,12 +48,20 @@ func (h *Handler) LoginPage(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 	// Check if user is authenticated
-	_, err := r.Cookie("token")
-	if err != nil {
+	current, ok := CurrentUserFrom(r.Context())
+	if !ok {
 		http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
 		return
 	}
 
+	// The page's scripts send this token with every state-changing request
+	csrfToken, err := utils.CSRFToken(current.SessionID())
+	if err != nil {
+		log.Println("❌ Failed to derive CSRF token:", err)
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return
+	}
+
 	tmplPath := filepath.Join("templates", "dashboard.html")
 	tmpl, err := template.ParseFiles(tmplPath)
 	if err != nil {
@@ -51,26 +69,100 @@ func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := tmpl.Execute(w, nil); err != nil {
+	data := struct {
+		Providers []*provider.Provider
+		CSRFToken string
+	}{
+		Providers: h.providers.All(),
+		CSRFToken: csrfToken,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 	}
 }
 
-// GoogleLogin: Generate and store state
-func (h *Handler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
-	state := "random_string" // Generate a proper random state
+// Login redirects to the named provider's consent screen asking only for identity scopes.
+// For Google, Calendar scopes are requested later through GoogleAuthorize. Passing
+// reauth=1 forces the consent prompt so the provider issues a fresh refresh token, and
+// return_to names a local page to continue on after signing in (default the dashboard).
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.providers.Get(mux.Vars(r)["provider"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if r.URL.Query().Get("reauth") == "1" {
+		opts = append(opts, oauth2.SetAuthURLParam("prompt", "consent"))
+	}
+	attempt := authAttempt{returnTo: localPath(r.URL.Query().Get("return_to"), "/api/dashboard")}
+	h.startAuthorization(w, r, p, p.OAuth, attempt, opts...)
+}
+
+// startAuthorization generates a random, single-use OAuth state and PKCE verifier,
+// binds the state to the browser with a short-lived cookie and redirects to the
+// provider's consent screen with the S256 code challenge.
+func (h *Handler) startAuthorization(w http.ResponseWriter, r *http.Request, p *provider.Provider, config *oauth2.Config, attempt authAttempt, extra ...oauth2.AuthCodeOption) {
+	verifier := oauth2.GenerateVerifier()
+	attempt.codeVerifier = verifier
+	attempt.provider = p.Name
+
+	state, err := h.states.Create(attempt)
+	if err != nil {
+		log.Println("❌ Failed to generate OAuth state:", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
 	http.SetCookie(w, &http.Cookie{
-		Name:     "oauthstate",
+		Name:     stateCookieName,
 		Value:    state,
+		Path:     "/auth",
+		MaxAge:   int(stateTTL.Seconds()),
 		HttpOnly: true,
 		Secure:   false, // Use true in production with HTTPS
+		SameSite: http.SameSiteLaxMode,
 	})
-	url := h.oauthConfig.AuthCodeURL(state)
+
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}
+	opts = append(opts, p.AuthParams...)
+	opts = append(opts, extra...)
+
+	url := config.AuthCodeURL(state, opts...)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
-// GoogleCallback handles the OAuth2 callback from Google
-func (h *Handler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
+// Callback handles the OAuth2 callback from the named provider
+func (h *Handler) Callback(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.providers.Get(mux.Vars(r)["provider"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Verify the state before looking at anything else in the response
+	attempt, err := h.verifyState(w, r)
+	if err == nil && attempt.provider != p.Name {
+		err = errors.New("state was issued for provider " + attempt.provider)
+	}
+	if err != nil {
+		log.Println("❌ OAuth state verification failed:", err)
+		h.auditCallback(r, attempt, p, audit.Failure, "state verification failed: "+err.Error())
+		renderError(w, http.StatusBadRequest, "Sign-in failed",
+			"Your sign-in request could not be verified. It may have expired or already been used. Please try again.")
+		return
+	}
+
+	// Providers report denied consent and other failures through the "error" parameter
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		log.Printf("❌ %s returned an error: %s", p.DisplayName, errParam)
+		h.auditCallback(r, attempt, p, audit.Failure, "provider returned error: "+errParam)
+		renderError(w, http.StatusUnauthorized, "Sign-in cancelled", p.DisplayName+" did not authorize the sign-in. Please try again.")
+		return
+	}
+
 	// Get "code" from URL parameters
 	code := r.URL.Query().Get("code")
 	if code == "" {
@@ -79,14 +171,14 @@ func (h *Handler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Exchange auth code for tokens (access token + ID token)
-	token, err := h.oauthConfig.Exchange(r.Context(), code)
+	// Exchange auth code for tokens (access token + ID token), proving possession of the PKCE verifier
+	token, err := p.OAuth.Exchange(r.Context(), code, oauth2.VerifierOption(attempt.codeVerifier))
 	if err != nil {
 		log.Println("❌ Failed to exchange token:", err)
+		h.auditCallback(r, attempt, p, audit.Failure, "token exchange failed: "+err.Error())
 		http.Error(w, "Failed to exchange token", http.StatusInternalServerError)
 		return
 	}
-	// log.Println("🔹 OAuth2 Token Response:", token)
 
 	// Extract ID Token from the token response
 	idToken, ok := token.Extra("id_token").(string)
@@ -96,206 +188,379 @@ func (h *Handler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create OIDC provider using Google
-	provider, err := oidc.NewProvider(r.Context(), "https://accounts.google.com")
-	if err != nil {
-		log.Println("❌ Failed to create OIDC provider:", err)
-		http.Error(w, "Failed to verify ID token", http.StatusInternalServerError)
-		return
-	}
-
 	// Verify and decode the ID Token
-	verifier := provider.Verifier(&oidc.Config{ClientID: h.oauthConfig.ClientID})
-	idTokenObj, err := verifier.Verify(r.Context(), idToken)
+	idTokenObj, err := p.Verifier.Verify(r.Context(), idToken)
 	if err != nil {
 		log.Println("❌ Invalid ID Token:", err)
+		h.auditCallback(r, attempt, p, audit.Failure, "invalid ID token: "+err.Error())
 		http.Error(w, "Invalid ID Token", http.StatusUnauthorized)
 		return
 	}
 
-	// Decode token claims to get user details
-	var userInfo struct {
-		Sub     string `json:"sub"`
-		Email   string `json:"email"`
-		Name    string `json:"name"`
-		Picture string `json:"picture"`
-	}
-
-	if err := idTokenObj.Claims(&userInfo); err != nil {
+	// Map the provider's claims to user details
+	profile, err := p.Profile(idTokenObj)
+	if err != nil {
 		log.Println("❌ Failed to parse ID Token claims:", err)
 		http.Error(w, "Failed to parse ID Token", http.StatusInternalServerError)
 		return
 	}
 
 	// Ensure required fields are present
-	if userInfo.Sub == "" || userInfo.Email == "" {
-		log.Println("❌ UserInfo missing required fields:", userInfo)
+	if profile.Subject == "" || profile.Email == "" {
+		log.Println("❌ Profile missing required fields:", profile)
 		http.Error(w, "Invalid user info received", http.StatusInternalServerError)
 		return
 	}
 
-	// Check if the user exists in the database
-	var existingUser models.User
-	result := h.DB.Where("google_id = ?", userInfo.Sub).First(&existingUser)
-
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			// New user, create record
-			newUser := models.User{
-				GoogleID:     userInfo.Sub,
-				Email:        userInfo.Email,
-				Name:         userInfo.Name,
-				Picture:      userInfo.Picture,
-				AccessToken:  token.AccessToken,
-				RefreshToken: token.RefreshToken,
-				ExpiresAt:    token.Expiry,
-			}
+	// Apply the sign-in policy to logins, links and authorizations alike
+	if err := h.access.Check(profile); err != nil {
+		h.denySignIn(w, r, p, profile, err)
+		return
+	}
 
-			// log.Println("🔹 New user, inserting into DB...")
-			if err := h.DB.Create(&newUser).Error; err != nil {
-				log.Println("❌ Error inserting user:", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			log.Println("❌ Error fetching user:", result.Error)
-			http.Error(w, "Database error", http.StatusInternalServerError)
-			return
-		}
-	} else {
-		// Existing user, update access token
+	// Google returns every scope granted to us so far because of include_granted_scopes
+	grantedScopes, _ := token.Extra("scope").(string)
+
+	switch attempt.purpose {
+	case purposeAuthorize:
+		// Incremental authorization by a signed-in user: update the identity's grant and go back
+		h.completeAuthorization(w, r, attempt, profile, token, grantedScopes)
+	case purposeLink:
+		// A signed-in user linking another account
+		h.completeLink(w, r, attempt, profile, token, grantedScopes)
+	default:
+		h.completeLogin(w, r, p, attempt, profile, token, grantedScopes)
+	}
+}
+
+// callbackActions maps the purpose of an authorization to the action it is audited as.
+var callbackActions = map[authPurpose]string{
+	purposeLogin:     audit.ActionLogin,
+	purposeAuthorize: audit.ActionAuthorize,
+	purposeLink:      audit.ActionLink,
+}
+
+// auditCallback records the outcome of a provider callback. The actor is the user who
+// started a link or authorization, if any; sign-ins record the user once known.
+func (h *Handler) auditCallback(r *http.Request, attempt authAttempt, p *provider.Provider, outcome, detail string) {
+	h.recordAudit(r, audit.Entry{
+		ActorID: attempt.userID,
+		Action:  callbackActions[attempt.purpose],
+		Target:  p.Name,
+		Outcome: outcome,
+		Detail:  detail,
+	})
+}
+
+// denySignIn records a sign-in rejected by the access policy and shows the denial page.
+func (h *Handler) denySignIn(w http.ResponseWriter, r *http.Request, p *provider.Provider, profile provider.Profile, err error) {
+	log.Println("❌ Sign-in denied:", err)
+
+	denied := models.DeniedSignIn{
+		ID:           uuid.New(),
+		Provider:     p.Name,
+		Subject:      profile.Subject,
+		Email:        profile.Email,
+		HostedDomain: profile.HostedDomain,
+		Detail:       err.Error(),
+		IP:           clientIP(r),
+		UserAgent:    r.UserAgent(),
+	}
+	var denial *access.Denial
+	if errors.As(err, &denial) {
+		denied.Reason = denial.Reason
+		denied.Detail = denial.Detail
+	}
+	if err := h.DB.Create(&denied).Error; err != nil {
+		log.Println("⚠️ Failed to record denied sign-in:", err)
+	}
+	h.recordAudit(r, audit.Entry{
+		ActorEmail: profile.Email,
+		Action:     audit.ActionLogin,
+		Target:     p.Name,
+		Outcome:    audit.Denied,
+		Detail:     denied.Reason + ": " + denied.Detail,
+	})
+
+	message := "The account " + profile.Email + " is not allowed to use this app. Please sign in with your organization's account or contact your administrator."
+	if denied.Reason == access.ReasonEmailUnverified {
+		message = "The email address " + profile.Email + " has not been verified with " + p.DisplayName + ". Please verify it and try again."
+	}
+	renderError(w, http.StatusForbidden, "Access denied", message)
+}
+
+// errEmailInUse is returned by signIn when a new identity's email belongs to an existing user.
+var errEmailInUse = errors.New("email already in use")
+
+// completeLogin signs the user in with the identity, creating the user on first sign-in.
+func (h *Handler) completeLogin(w http.ResponseWriter, r *http.Request, p *provider.Provider, attempt authAttempt, profile provider.Profile, token *oauth2.Token, grantedScopes string) {
+	user, err := h.signIn(p, profile, token, grantedScopes)
+	if errors.Is(err, errEmailInUse) {
+		log.Println("❌ Email already registered with another account:", profile.Email)
+		h.recordAudit(r, audit.Entry{ActorEmail: profile.Email, Action: audit.ActionLogin, Target: p.Name, Outcome: audit.Denied, Detail: err.Error()})
+		renderError(w, http.StatusConflict, "Account already exists",
+			"An account with this email already exists. Sign in with the method you used before, then link this account from the dashboard.")
+		return
+	}
+	if err != nil {
+		log.Println("❌ Error saving user:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Issue our own session token instead of handing the provider's ID token to the browser
+	if err := h.issueSession(w, r, user.ID); err != nil {
+		log.Println("❌ Failed to issue session token:", err)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(r, audit.Entry{ActorID: user.ID, ActorEmail: profile.Email, Action: audit.ActionLogin, Target: p.Name, Outcome: audit.Success})
+
+	// Redirect to dashboard or the page the login was started from
+	http.Redirect(w, r, attempt.returnTo, http.StatusTemporaryRedirect)
+}
+
+// signIn finds the user owning the provider identity and stores the tokens from the
+// login. On first sign-in it creates the user together with the identity.
+func (h *Handler) signIn(p *provider.Provider, profile provider.Profile, token *oauth2.Token, grantedScopes string) (*models.User, error) {
+	var identity models.Identity
+	err := h.DB.Where("provider = ? AND subject = ?", p.Name, profile.Subject).First(&identity).Error
+	if err == nil {
+		// Existing identity, update its tokens
 		log.Println("🔹 Existing user found, updating tokens...")
-		existingUser.AccessToken = token.AccessToken
-		existingUser.ExpiresAt = token.Expiry
+		if err := h.DB.Model(&identity).Updates(identityUpdates(profile, token, grantedScopes)).Error; err != nil {
+			return nil, err
+		}
 
-		if token.RefreshToken != "" {
-			existingUser.RefreshToken = token.RefreshToken
+		var user models.User
+		if err := h.DB.Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+			return nil, err
 		}
 
-		if err := h.DB.Save(&existingUser).Error; err != nil {
-			log.Println("❌ Error updating user:", err)
-			http.Error(w, "Database error", http.StatusInternalServerError)
-			return
+		// Bootstrap administrators listed in the configuration, if the provider vouches for the address
+		if user.Role != models.RoleAdmin && profile.EmailVerified && strings.EqualFold(profile.Email, user.Email) && h.roles.isAdminEmail(user.Email) {
+			log.Println("🔹 Promoting configured administrator:", user.Email)
+			if err := h.DB.Model(&user).Update("role", models.RoleAdmin).Error; err != nil {
+				return nil, err
+			}
 		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
 	}
 
-	// Store token in a cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    idToken,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false,
-		SameSite: http.SameSiteLaxMode,
+	// New user, unless the email already belongs to someone who should link this identity instead
+	var count int64
+	if err := h.DB.Model(&models.User{}).Where("email = ?", profile.Email).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errEmailInUse
+	}
+
+	user := models.User{
+		ID:      uuid.New(),
+		Email:   profile.Email,
+		Name:    profile.Name,
+		Picture: profile.Picture,
+		Role:    h.roles.roleFor(profile),
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		identity := newIdentity(user.ID, p, profile, token, grantedScopes)
+		return tx.Create(&identity).Error
 	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// completeAuthorization stores the tokens and scopes from an incremental authorization
+// after checking that the account is one of the signed-in user's linked identities.
+func (h *Handler) completeAuthorization(w http.ResponseWriter, r *http.Request, attempt authAttempt, profile provider.Profile, token *oauth2.Token, grantedScopes string) {
+	var identity models.Identity
+	err := h.DB.Where("provider = ? AND subject = ? AND user_id = ?", attempt.provider, profile.Subject, attempt.userID).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("❌ Authorization completed with an account that is not linked")
+		h.recordAudit(r, audit.Entry{ActorID: attempt.userID, ActorEmail: profile.Email, Action: audit.ActionAuthorize, Target: attempt.provider, Outcome: audit.Denied, Detail: "account is not linked"})
+		renderError(w, http.StatusForbidden, "Account not linked",
+			"Please grant calendar access with a Google account linked to your profile, or link this account from the dashboard first.")
+		return
+	}
+	if err != nil {
+		log.Println("❌ Failed to retrieve identity from DB:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
 
-	// log.Println("✅ User authenticated successfully:", userInfo.Email)
+	if err := h.DB.Model(&identity).Updates(identityUpdates(profile, token, grantedScopes)).Error; err != nil {
+		log.Println("❌ Error updating identity:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
 
-	// Redirect to dashboard or another relevant page
-	http.Redirect(w, r, "/api/dashboard", http.StatusTemporaryRedirect)
+	h.recordAudit(r, audit.Entry{
+		ActorID:    attempt.userID,
+		ActorEmail: profile.Email,
+		Action:     audit.ActionAuthorize,
+		Target:     identity.ID.String(),
+		Outcome:    audit.Success,
+		Detail:     grantedScopes,
+	})
+	log.Println("✅ Additional scopes granted:", grantedScopes)
+	http.Redirect(w, r, attempt.returnTo, http.StatusTemporaryRedirect)
 }
 
-func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
-	// Clear the token cookie
+// newIdentity builds the Identity for a provider account signing in for the first time.
+func newIdentity(userID uuid.UUID, p *provider.Provider, profile provider.Profile, token *oauth2.Token, grantedScopes string) models.Identity {
+	return models.Identity{
+		ID:           uuid.New(),
+		UserID:       userID,
+		Provider:     p.Name,
+		Subject:      profile.Subject,
+		Email:        profile.Email,
+		AccessToken:  models.EncryptedString(token.AccessToken),
+		RefreshToken: models.EncryptedString(token.RefreshToken),
+		ExpiresAt:    token.Expiry,
+
+		GrantedScopes: grantedScopes,
+	}
+}
+
+// identityUpdates returns the columns to update on an existing identity after a successful authorization.
+// The refresh token is only replaced when the provider issued a new one.
+func identityUpdates(profile provider.Profile, token *oauth2.Token, grantedScopes string) map[string]interface{} {
+	updates := map[string]interface{}{
+		"email":              profile.Email,
+		"access_token":       models.EncryptedString(token.AccessToken),
+		"expires_at":         token.Expiry,
+		"granted_scopes":     grantedScopes,
+		"needs_reauth":       false,
+		"refresh_failures":   0,
+		"last_refresh_error": "",
+		"next_refresh_at":    nil,
+	}
+	if token.RefreshToken != "" {
+		updates["refresh_token"] = models.EncryptedString(token.RefreshToken)
+	}
+	return updates
+}
+
+// localPath returns path if it is a path on this site, or fallback otherwise, so that
+// redirect targets taken from the query string cannot send users to other sites.
+func localPath(path, fallback string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return fallback
+	}
+	return path
+}
+
+// verifyState checks the "state" query parameter against the oauthstate cookie
+// and consumes it from the server-side store so it cannot be replayed.
+// The cookie is cleared regardless of the outcome.
+func (h *Handler) verifyState(w http.ResponseWriter, r *http.Request) (authAttempt, error) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
+		Name:     stateCookieName,
 		Value:    "",
-		Path:     "/",
+		Path:     "/auth",
 		MaxAge:   -1,
 		HttpOnly: true,
 	})
-	http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
-}
 
-// Secret Key for JWT decoding (replace with your actual key)
-var jwtSecret = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890")
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		return authAttempt{}, errors.New("missing state parameter")
+	}
 
-// getUserTokenFromDB retrieves the OAuth token using the user's email extracted from the ID token
-func (h *Handler) getUserTokenFromDB(r *http.Request) (*oauth2.Token, string, error) {
-	// Get the token from the cookie
-	cookie, err := r.Cookie("token")
-	if err != nil {
-		log.Println("❌ No token cookie found")
-		return nil, "", errors.New("user not authenticated")
+	cookie, err := r.Cookie(stateCookieName)
+	if err != nil || cookie.Value == "" {
+		return authAttempt{}, errors.New("missing state cookie")
 	}
 
-	// Extract ID token from the cookie
-	idTokenString := cookie.Value
+	if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return authAttempt{}, errors.New("state does not match cookie")
+	}
 
-	// Create OIDC provider for Google
-	provider, err := oidc.NewProvider(r.Context(), "https://accounts.google.com")
-	if err != nil {
-		log.Println("❌ Failed to create OIDC provider:", err)
-		return nil, "", errors.New("failed to create OIDC provider")
+	attempt, ok := h.states.Consume(state)
+	if !ok {
+		return authAttempt{}, errors.New("state is unknown, expired or already used")
 	}
+	return attempt, nil
+}
 
-	// Create a verifier to validate Google's ID token
-	verifier := provider.Verifier(&oidc.Config{ClientID: h.oauthConfig.ClientID})
+// Logout revokes the current session server-side and clears the token cookie
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if claims, userID, ok := h.cookieSession(r); ok {
+		// Stop other sites from logging users out
+		if !validCSRF(r, claims) {
+			log.Println("❌ CSRF check failed for logout")
+			writeCSRFError(w)
+			return
+		}
 
-	// Verify and parse the ID token
-	idToken, err := verifier.Verify(r.Context(), idTokenString)
-	if err != nil {
-		log.Println("❌ Failed to verify ID token:", err)
-		return nil, "", errors.New("invalid token")
+		outcome, detail := audit.Success, ""
+		if err := h.sessions.Revoke(r.Context(), claims.SessionID); err != nil {
+			log.Println("❌ Failed to revoke session:", err)
+			outcome, detail = audit.Failure, err.Error()
+		}
+		h.recordAudit(r, audit.Entry{ActorID: userID, Action: audit.ActionLogout, Target: claims.SessionID, Outcome: outcome, Detail: detail})
 	}
 
-	// Extract user claims
-	var claims struct {
-		Email string `json:"email"`
-	}
-	if err := idToken.Claims(&claims); err != nil {
-		log.Println("❌ Failed to parse ID token claims:", err)
-		return nil, "", errors.New("failed to parse ID token")
-	}
+	// Clear the token cookie
+	clearSessionCookie(w)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
 
-	// Ensure email exists
-	if claims.Email == "" {
-		log.Println("❌ Email not found in token claims")
-		return nil, "", errors.New("invalid token data")
+// calendarIdentities returns the signed-in user's Google identities that have granted
+// the Calendar access level, oldest first. If the user has Google identities but none
+// with the access level the error is an *insufficientScopeError; if the user has no
+// Google identity at all it is errGoogleAccountRequired.
+func (h *Handler) calendarIdentities(r *http.Request, access calendarAccess) ([]models.Identity, error) {
+	current, ok := CurrentUserFrom(r.Context())
+	if !ok {
+		log.Println("❌ No user found in request context")
+		return nil, errors.New("user not authenticated")
 	}
 
-	// Fetch user from the database using email
-	var user models.User
-	if err := h.DB.Where("email = ?", claims.Email).First(&user).Error; err != nil {
-		log.Println("❌ Failed to retrieve user from DB:", err)
-		return nil, "", errors.New("failed to retrieve user token")
+	var identities []models.Identity
+	if err := h.DB.Where("user_id = ? AND provider = ?", current.ID(), googleProviderName).Order("created_at").Find(&identities).Error; err != nil {
+		log.Println("❌ Failed to retrieve identities from DB:", err)
+		return nil, errors.New("failed to retrieve user token")
 	}
-
-	// Construct token object
-	oauthToken := &oauth2.Token{
-		AccessToken:  user.AccessToken,
-		RefreshToken: user.RefreshToken,
-		Expiry:       user.ExpiresAt,
+	if len(identities) == 0 {
+		return nil, errGoogleAccountRequired
 	}
 
-	// Check if the token is expired and refresh if needed
-	if time.Now().After(user.ExpiresAt) {
-		log.Println("🔄 Token expired, refreshing...")
-
-		newToken, err := h.refreshAccessToken(user.RefreshToken)
-		if err != nil {
-			log.Println("❌ Failed to refresh token:", err)
-			return nil, "", errors.New("failed to refresh token")
+	// Check the granted scopes before calling the Calendar API
+	var granted []models.Identity
+	for _, identity := range identities {
+		if hasCalendarAccess(identity.GrantedScopes, access) {
+			granted = append(granted, identity)
 		}
-
-		// Update user token in DB
-		user.AccessToken = newToken.AccessToken
-		user.ExpiresAt = newToken.Expiry
-		h.DB.Save(&user)
-
-		return newToken, claims.Email, nil
 	}
-
-	log.Println("✅ Token retrieved successfully")
-	return oauthToken, claims.Email, nil
+	if len(granted) == 0 {
+		return nil, &insufficientScopeError{access: access, identityID: identities[0].ID}
+	}
+	return granted, nil
 }
 
-// Function to refresh the access token using the refresh token
-func (h *Handler) refreshAccessToken(refreshToken string) (*oauth2.Token, error) {
-	tokenSource := h.oauthConfig.TokenSource(oauth2.NoContext, &oauth2.Token{RefreshToken: refreshToken})
-	newToken, err := tokenSource.Token()
+// calendarService returns a Google Calendar client authorized as the identity.
+// Refreshed tokens are persisted by the token manager; if the grant is no longer
+// usable the error matches tokens.ErrReauthRequired.
+func (h *Handler) calendarService(r *http.Request, identity *models.Identity) (*calendar.Service, error) {
+	// Obtain a valid token up front so an unusable grant is reported before any API call
+	token, err := h.tokens.Token(r.Context(), identity.ID)
 	if err != nil {
+		log.Println("❌ Failed to retrieve token:", err)
+		h.recordAudit(r, audit.Entry{ActorID: identity.UserID, ActorEmail: identity.Email, Action: audit.ActionTokenFetch, Target: identity.ID.String(), Outcome: audit.Failure, Detail: err.Error()})
 		return nil, err
 	}
-	return newToken, nil
+
+	tokenSource := oauth2.ReuseTokenSource(token, h.tokens.TokenSource(r.Context(), identity.ID))
+	return calendar.NewService(r.Context(), option.WithTokenSource(tokenSource))
 }
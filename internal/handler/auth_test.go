@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"google-calendar-api/internal/provider"
+
+	"golang.org/x/oauth2"
+)
+
+func TestStartAuthorizationPKCE(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    []oauth2.AuthCodeOption
+		extra     []oauth2.AuthCodeOption
+		wantQuery map[string]string
+	}{
+		{
+			name: "no extra parameters",
+		},
+		{
+			name:      "provider parameters",
+			params:    []oauth2.AuthCodeOption{oauth2.AccessTypeOffline},
+			wantQuery: map[string]string{"access_type": "offline"},
+		},
+		{
+			name:      "request parameters",
+			extra:     []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("prompt", "consent")},
+			wantQuery: map[string]string{"prompt": "consent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{states: newStateStore(time.Minute)}
+			p := &provider.Provider{
+				Name:       "example",
+				AuthParams: tt.params,
+				OAuth: &oauth2.Config{
+					ClientID: "client",
+					Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/authorize"},
+				},
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/auth/example/login", nil)
+			h.startAuthorization(w, r, p, p.OAuth, authAttempt{}, tt.extra...)
+
+			if w.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusTemporaryRedirect)
+			}
+			location, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("invalid redirect: %v", err)
+			}
+			query := location.Query()
+
+			state := query.Get("state")
+			var cookie *http.Cookie
+			for _, c := range w.Result().Cookies() {
+				if c.Name == stateCookieName {
+					cookie = c
+				}
+			}
+			if cookie == nil || cookie.Value != state || !cookie.HttpOnly {
+				t.Fatalf("state cookie = %+v, want an HttpOnly cookie holding %q", cookie, state)
+			}
+
+			attempt, ok := h.states.Consume(state)
+			if !ok {
+				t.Fatal("redirect state was not registered")
+			}
+			if attempt.provider != "example" {
+				t.Errorf("attempt provider = %q, want example", attempt.provider)
+			}
+			if len(attempt.codeVerifier) < 43 {
+				t.Fatalf("code verifier %q is shorter than RFC 7636 allows", attempt.codeVerifier)
+			}
+
+			sum := sha256.Sum256([]byte(attempt.codeVerifier))
+			if got, want := query.Get("code_challenge"), base64.RawURLEncoding.EncodeToString(sum[:]); got != want {
+				t.Errorf("code_challenge = %q, want S256 of the stored verifier %q", got, want)
+			}
+			if got := query.Get("code_challenge_method"); got != "S256" {
+				t.Errorf("code_challenge_method = %q, want S256", got)
+			}
+			for key, want := range tt.wantQuery {
+				if got := query.Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStartAuthorizationFreshVerifier(t *testing.T) {
+	h := &Handler{states: newStateStore(time.Minute)}
+	p := &provider.Provider{Name: "example", OAuth: &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/authorize"}}}
+
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		h.startAuthorization(w, httptest.NewRequest(http.MethodGet, "/auth/example/login", nil), p, p.OAuth, authAttempt{})
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("invalid redirect: %v", err)
+		}
+		challenge := location.Query().Get("code_challenge")
+		if seen[challenge] {
+			t.Fatalf("code challenge %q was reused", challenge)
+		}
+		seen[challenge] = true
+	}
+}
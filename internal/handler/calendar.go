@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"google-calendar-api/models"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// primaryCalendar names the main calendar of whichever Google account is used.
+const primaryCalendar = "primary"
+
+// errCalendarNotFound is returned when none of the user's accounts can see a calendar.
+var errCalendarNotFound = errors.New("calendar not found")
+
+// defaultCalendar returns the calendar used when a request does not name one.
+func defaultCalendar(user *models.User) string {
+	if user.DefaultCalendarID == "" {
+		return primaryCalendar
+	}
+	return user.DefaultCalendarID
+}
+
+// calendarParams returns the calendars named by the calendarId query parameter, which
+// may be repeated or hold a comma-separated list.
+func calendarParams(r *http.Request) []string {
+	var calendarIDs []string
+	for _, value := range r.URL.Query()["calendarId"] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				calendarIDs = append(calendarIDs, id)
+			}
+		}
+	}
+	return calendarIDs
+}
+
+// calendarAccount returns the first of the identities that can see the calendar and a
+// client authorized as it. Every account has a primary calendar, so that one is the
+// first identity's. Other calendars are probed with a one-event listing, which works
+// with every Calendar scope.
+func (h *Handler) calendarAccount(r *http.Request, identities []models.Identity, calendarID string) (*models.Identity, *calendar.Service, error) {
+	var firstErr error
+	for i := range identities {
+		service, err := h.calendarService(r, &identities[i])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if calendarID == primaryCalendar {
+			return &identities[i], service, nil
+		}
+
+		_, err = service.Events.List(calendarID).MaxResults(1).Fields("kind").Context(r.Context()).Do()
+		if err == nil {
+			return &identities[i], service, nil
+		}
+		if !isNotFound(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return nil, nil, errCalendarNotFound
+}
+
+// writeCalendarError reports a failure to find a calendar.
+func writeCalendarError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, errCalendarNotFound) {
+		writeJSONError(w, http.StatusNotFound, apiError{
+			Error:   "calendar_not_found",
+			Message: "The calendar does not exist in any of your linked Google accounts.",
+		})
+		return
+	}
+	writeTokenError(w, err, http.StatusInternalServerError, message)
+}
+
+// ListCalendars returns the calendars of every linked Google account that granted
+// read access to calendars, marking the user's default. The event scopes do not
+// allow listing calendars; if no account granted more, the response is
+// insufficient_scope with the URL to grant it.
+func (h *Handler) ListCalendars(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	defaultID := defaultCalendar(&current.User)
+
+	identities, err := h.calendarIdentities(r, accessCalendars)
+	if err != nil {
+		log.Println("[ERROR] Failed to retrieve user token:", err)
+		writeTokenError(w, err, http.StatusUnauthorized, "Failed to retrieve token")
+		return
+	}
+
+	calendars := []map[string]interface{}{}
+	unavailable := []map[string]string{}
+	var firstErr error
+	for i := range identities {
+		identity := &identities[i]
+		var items []*calendar.CalendarListEntry
+		service, err := h.calendarService(r, identity)
+		if err == nil {
+			err = service.CalendarList.List().Pages(r.Context(), func(page *calendar.CalendarList) error {
+				items = append(items, page.Items...)
+				return nil
+			})
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to list calendars for %s: %v", identity.Email, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			unavailable = append(unavailable, map[string]string{
+				"identity_id": identity.ID.String(),
+				"email":       identity.Email,
+			})
+			continue
+		}
+
+		for _, item := range items {
+			calendars = append(calendars, map[string]interface{}{
+				"id":          item.Id,
+				"summary":     item.Summary,
+				"primary":     item.Primary,
+				"access_role": item.AccessRole,
+				"time_zone":   item.TimeZone,
+				"default":     item.Id == defaultID || (item.Primary && defaultID == primaryCalendar),
+				"account":     identity.Email,
+				"identity_id": identity.ID,
+			})
+		}
+	}
+	if len(unavailable) == len(identities) {
+		writeTokenError(w, firstErr, http.StatusInternalServerError, "Failed to fetch Google calendars")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"calendars":            calendars,
+		"default_calendar_id":  defaultID,
+		"unavailable_accounts": unavailable,
+	})
+}
+
+// SetDefaultCalendar stores the calendar that event requests use when they do not
+// name one. The calendar must be visible to one of the user's linked accounts.
+func (h *Handler) SetDefaultCalendar(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		CalendarID string `json:"calendar_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || strings.TrimSpace(request.CalendarID) == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	calendarID := strings.TrimSpace(request.CalendarID)
+
+	identities, err := h.calendarIdentities(r, accessRead)
+	if err != nil {
+		log.Println("[ERROR] Failed to retrieve user token:", err)
+		writeTokenError(w, err, http.StatusUnauthorized, "Failed to retrieve token")
+		return
+	}
+	if _, _, err := h.calendarAccount(r, identities, calendarID); err != nil {
+		log.Println("[ERROR] Failed to find calendar:", err)
+		writeCalendarError(w, err, "Failed to find calendar")
+		return
+	}
+
+	if err := h.DB.Model(&current.User).Update("default_calendar_id", calendarID).Error; err != nil {
+		log.Println("❌ Failed to save default calendar:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"default_calendar_id": calendarID})
+}
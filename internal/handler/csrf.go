@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"google-calendar-api/utils"
+)
+
+// CSRF tokens are sent by scripts in a header and by HTML forms in a field.
+const (
+	csrfHeaderName = "X-CSRF-Token"
+	csrfFieldName  = "csrf_token"
+)
+
+// safeMethods do not change state and are not checked for CSRF.
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// validCSRF reports whether a state-changing request authenticated by the session
+// cookie carries the session's CSRF token. Safe methods always pass.
+func validCSRF(r *http.Request, claims *utils.SessionClaims) bool {
+	if safeMethods[r.Method] {
+		return true
+	}
+
+	expected, err := utils.CSRFToken(claims.SessionID)
+	if err != nil {
+		log.Println("❌ Failed to derive CSRF token:", err)
+		return false
+	}
+
+	sent := r.Header.Get(csrfHeaderName)
+	if sent == "" {
+		sent = r.PostFormValue(csrfFieldName)
+	}
+	return sent != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(expected)) == 1
+}
+
+// writeCSRFError rejects a request whose CSRF token is missing or wrong.
+func writeCSRFError(w http.ResponseWriter) {
+	writeJSONError(w, http.StatusForbidden, apiError{
+		Error:   "csrf_token_invalid",
+		Message: "Missing or invalid CSRF token. Reload the page and try again.",
+	})
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"google-calendar-api/utils"
+)
+
+func TestValidCSRF(t *testing.T) {
+	t.Setenv("SECRET_KEY", "test-secret-key")
+	claims := &utils.SessionClaims{SessionID: "session-1"}
+	token, err := utils.CSRFToken(claims.SessionID)
+	if err != nil {
+		t.Fatalf("CSRFToken: %v", err)
+	}
+	otherSession, err := utils.CSRFToken("session-2")
+	if err != nil {
+		t.Fatalf("CSRFToken: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		field  string
+		want   bool
+	}{
+		{name: "safe method without token", method: http.MethodGet, want: true},
+		{name: "header token", method: http.MethodPost, header: token, want: true},
+		{name: "form token", method: http.MethodPost, field: token, want: true},
+		{name: "missing token", method: http.MethodPost},
+		{name: "missing token on delete", method: http.MethodDelete},
+		{name: "token of another session", method: http.MethodPost, header: otherSession},
+		{name: "tampered token", method: http.MethodPatch, header: token[:len(token)-1] + "x"},
+		{name: "wrong header wins over form", method: http.MethodPost, header: otherSession, field: token},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r *http.Request
+			if tt.field != "" {
+				form := url.Values{csrfFieldName: {tt.field}}
+				r = httptest.NewRequest(tt.method, "/api/events", strings.NewReader(form.Encode()))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				r = httptest.NewRequest(tt.method, "/api/events", nil)
+			}
+			if tt.header != "" {
+				r.Header.Set(csrfHeaderName, tt.header)
+			}
+
+			if got := validCSRF(r, claims); got != tt.want {
+				t.Errorf("validCSRF = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"google-calendar-api/models"
+	"google-calendar-api/utils"
+
+	"github.com/google/uuid"
+)
+
+// CurrentUser is the authenticated caller of a request. AuthMiddleware loads it once
+// per request, including the user's database row, so handlers never parse the token
+// or look the user up again.
+type CurrentUser struct {
+	User        models.User                 // The user's database row
+	Claims      *utils.SessionClaims        // Session token claims; SessionID is empty for access tokens
+	AccessToken *models.PersonalAccessToken // Personal access token that authenticated the request, if any
+}
+
+// ID returns the user's ID.
+func (u *CurrentUser) ID() uuid.UUID {
+	return u.User.ID
+}
+
+// SessionID returns the ID of the login session making the request, or "" for
+// requests authenticated with a personal access token.
+func (u *CurrentUser) SessionID() string {
+	return u.Claims.SessionID
+}
+
+// CurrentUserFrom returns the user stored in the context by AuthMiddleware.
+func CurrentUserFrom(ctx context.Context) (*CurrentUser, bool) {
+	user, ok := ctx.Value(userKey).(*CurrentUser)
+	return user, ok
+}
+
+// withCurrentUser returns a copy of ctx carrying the user.
+func withCurrentUser(ctx context.Context, user *CurrentUser) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// requireUser returns the current user, writing a 401 response if the request
+// did not pass through AuthMiddleware.
+func requireUser(w http.ResponseWriter, r *http.Request) (*CurrentUser, bool) {
+	user, ok := CurrentUserFrom(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+// requireSessionUser is like requireUser but also rejects personal access tokens with
+// a 403. Routes that change how the user signs in, such as linking an account, are
+// not covered by token scopes and must not be reachable with a token.
+func requireSessionUser(w http.ResponseWriter, r *http.Request) (*CurrentUser, bool) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if user.AccessToken != nil {
+		log.Printf("❌ Personal access token %s used for %s", user.AccessToken.Prefix, r.URL.Path)
+		writeJSONError(w, http.StatusForbidden, apiError{
+			Error:   "session_required",
+			Message: "This action requires signing in; personal access tokens cannot be used.",
+		})
+		return nil, false
+	}
+	return user, true
+}
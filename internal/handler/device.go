@@ -0,0 +1,422 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/internal/provider"
+	"google-calendar-api/utils"
+
+	"github.com/google/uuid"
+)
+
+// deviceGrantType is the grant_type clients poll the token endpoint with (RFC 8628, section 3.4).
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Device code defaults, overridden by DEVICE_CODE_TTL and DEVICE_POLL_INTERVAL.
+const (
+	defaultDeviceCodeTTL      = 10 * time.Minute
+	defaultDevicePollInterval = 5 * time.Second
+)
+
+// userCodeAlphabet avoids vowels and look-alike characters so user codes are easy
+// to type and never spell words (RFC 8628, section 6.1).
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// Device authorization errors returned by the token endpoint (RFC 8628, section 3.5).
+var (
+	errAuthorizationPending = errors.New("authorization_pending")
+	errSlowDown             = errors.New("slow_down")
+	errAccessDenied         = errors.New("access_denied")
+	errExpiredToken         = errors.New("expired_token")
+	errInvalidDeviceCode    = errors.New("invalid_grant")
+)
+
+// deviceStatus is the state of a device authorization.
+type deviceStatus int
+
+const (
+	devicePending deviceStatus = iota
+	deviceApproved
+	deviceDenied
+)
+
+// deviceGrant is a pending device authorization started by a command-line client.
+type deviceGrant struct {
+	deviceCode string        // Secret code the client polls with
+	userCode   string        // Short code the user types on the verification page, without the dash
+	clientIP   string        // IP address of the client that requested the codes
+	userAgent  string        // User agent of the client that requested the codes
+	expiresAt  time.Time     // Moment after which the codes are no longer accepted
+	interval   time.Duration // Minimum time between polls
+	lastPolled time.Time     // Time of the client's last poll
+	status     deviceStatus  // Whether the user has approved or denied the request
+	userID     uuid.UUID     // User who approved the request
+}
+
+// deviceStore keeps pending device authorizations in memory, like stateStore does for
+// browser logins. Approved grants are handed out exactly once.
+type deviceStore struct {
+	mu       sync.Mutex
+	byDevice map[string]*deviceGrant
+	byUser   map[string]*deviceGrant
+	ttl      time.Duration
+	interval time.Duration
+}
+
+// newDeviceStore creates an empty store whose codes expire after ttl and may be polled every interval.
+func newDeviceStore(ttl, interval time.Duration) *deviceStore {
+	return &deviceStore{
+		byDevice: make(map[string]*deviceGrant),
+		byUser:   make(map[string]*deviceGrant),
+		ttl:      ttl,
+		interval: interval,
+	}
+}
+
+// Create starts a device authorization for the requesting client.
+func (s *deviceStore) Create(r *http.Request) (deviceGrant, error) {
+	deviceCode, err := randomString(32)
+	if err != nil {
+		return deviceGrant{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+
+	// Retry the unlikely collision with another pending user code
+	var userCode string
+	for {
+		if userCode, err = randomUserCode(8); err != nil {
+			return deviceGrant{}, err
+		}
+		if _, taken := s.byUser[userCode]; !taken {
+			break
+		}
+	}
+
+	grant := &deviceGrant{
+		deviceCode: deviceCode,
+		userCode:   userCode,
+		clientIP:   clientIP(r),
+		userAgent:  r.UserAgent(),
+		expiresAt:  time.Now().Add(s.ttl),
+		interval:   s.interval,
+	}
+	s.byDevice[deviceCode] = grant
+	s.byUser[userCode] = grant
+	return *grant, nil
+}
+
+// Lookup returns the pending authorization for a user code.
+func (s *deviceStore) Lookup(userCode string) (deviceGrant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant, ok := s.byUser[normalizeUserCode(userCode)]
+	if !ok || grant.status != devicePending || time.Now().After(grant.expiresAt) {
+		return deviceGrant{}, false
+	}
+	return *grant, true
+}
+
+// Decide records the user's approval or denial of a pending authorization.
+// It reports false if the user code is unknown, expired or already decided.
+func (s *deviceStore) Decide(userCode string, userID uuid.UUID, approve bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant, ok := s.byUser[normalizeUserCode(userCode)]
+	if !ok || grant.status != devicePending || time.Now().After(grant.expiresAt) {
+		return false
+	}
+	if approve {
+		grant.status = deviceApproved
+		grant.userID = userID
+	} else {
+		grant.status = deviceDenied
+	}
+	return true
+}
+
+// Poll returns the approved authorization for a device code and removes it, or one
+// of the RFC 8628 errors while it is pending, denied or expired.
+func (s *deviceStore) Poll(deviceCode string) (deviceGrant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant, ok := s.byDevice[deviceCode]
+	if !ok {
+		return deviceGrant{}, errInvalidDeviceCode
+	}
+
+	now := time.Now()
+	if now.After(grant.expiresAt) {
+		s.remove(grant)
+		return deviceGrant{}, errExpiredToken
+	}
+
+	switch grant.status {
+	case deviceApproved:
+		s.remove(grant)
+		return *grant, nil
+	case deviceDenied:
+		s.remove(grant)
+		return deviceGrant{}, errAccessDenied
+	}
+
+	// Clients polling faster than the interval must back off by 5 seconds (section 3.5)
+	tooSoon := now.Sub(grant.lastPolled) < grant.interval
+	grant.lastPolled = now
+	if tooSoon {
+		grant.interval += 5 * time.Second
+		return deviceGrant{}, errSlowDown
+	}
+	return deviceGrant{}, errAuthorizationPending
+}
+
+// remove drops a grant. The caller must hold s.mu.
+func (s *deviceStore) remove(grant *deviceGrant) {
+	delete(s.byDevice, grant.deviceCode)
+	delete(s.byUser, grant.userCode)
+}
+
+// prune drops expired grants. The caller must hold s.mu.
+func (s *deviceStore) prune() {
+	now := time.Now()
+	for _, grant := range s.byDevice {
+		if now.After(grant.expiresAt) {
+			s.remove(grant)
+		}
+	}
+}
+
+// randomUserCode returns n characters drawn uniformly from userCodeAlphabet.
+func randomUserCode(n int) (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, n)
+	for i := range code {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[idx.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode uppercases a typed user code and drops dashes and spaces.
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// formatUserCode inserts a dash in the middle of a user code for display, e.g. "BCDF-GHJK".
+func formatUserCode(code string) string {
+	return code[:len(code)/2] + "-" + code[len(code)/2:]
+}
+
+// DeviceCode starts a device authorization for a command-line client (RFC 8628, section 3.1).
+// The client shows the user code and verification URI, then polls DeviceToken.
+func (h *Handler) DeviceCode(w http.ResponseWriter, r *http.Request) {
+	grant, err := h.devices.Create(r)
+	if err != nil {
+		log.Println("❌ Failed to create device code:", err)
+		http.Error(w, "Failed to start device authorization", http.StatusInternalServerError)
+		return
+	}
+
+	verificationURI := publicURL(r) + "/device"
+	userCode := formatUserCode(grant.userCode)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_code":               grant.deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		"expires_in":                int(time.Until(grant.expiresAt).Seconds()),
+		"interval":                  int(grant.interval.Seconds()),
+	})
+}
+
+// DeviceToken is polled by the client until the user approves or denies the request
+// (RFC 8628, section 3.4). Once approved it issues an application session token for
+// the approving user, usable as a Bearer token.
+func (h *Handler) DeviceToken(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("grant_type") != deviceGrantType {
+		writeJSONError(w, http.StatusBadRequest, apiError{
+			Error:   "unsupported_grant_type",
+			Message: "grant_type must be " + deviceGrantType,
+		})
+		return
+	}
+
+	grant, err := h.devices.Poll(r.FormValue("device_code"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, apiError{Error: err.Error(), Message: deviceErrorMessage(err)})
+		return
+	}
+
+	s, err := h.createSession(r, grant.userID)
+	if err != nil {
+		log.Println("❌ Failed to create device session:", err)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	token, err := utils.GenerateToken(grant.userID, s.ID, h.sessionTTL)
+	if err != nil {
+		log.Println("❌ Failed to issue session token:", err)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	// The user code identifies the request in the approval entry; the device code is a secret
+	h.recordAudit(r, audit.Entry{ActorID: grant.userID, Action: audit.ActionDeviceToken, Target: grant.userCode, Outcome: audit.Success, Detail: "session " + s.ID})
+	log.Println("✅ Device authorized for user", grant.userID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(h.sessionTTL.Seconds()),
+	})
+}
+
+// deviceErrorMessage describes a device token error for people reading the client's output.
+func deviceErrorMessage(err error) string {
+	switch err {
+	case errAuthorizationPending:
+		return "The user has not approved the request yet."
+	case errSlowDown:
+		return "Polling too fast; increase the interval by 5 seconds."
+	case errAccessDenied:
+		return "The user denied the request."
+	case errExpiredToken:
+		return "The device code has expired. Start again."
+	default:
+		return "The device code is invalid."
+	}
+}
+
+// devicePage is the data rendered by templates/device.html.
+type devicePage struct {
+	Providers []*provider.Provider // Sign-in options when the user is not signed in
+	ReturnTo  string               // Page to come back to after signing in
+	SignedIn  bool                 // Whether the user has a session
+	UserCode  string               // Code being confirmed, formatted for display
+	ClientIP  string               // IP address of the device that requested the code
+	Error     string               // Problem with the entered code
+	Done      string               // Outcome once the user approved or denied
+	CSRFToken string               // Anti-CSRF token for the approval form
+}
+
+// DevicePage is the verification page where users enter the code shown by their
+// command-line client. Users who are not signed in are sent through the regular
+// provider login first and brought back here.
+func (h *Handler) DevicePage(w http.ResponseWriter, r *http.Request) {
+	userCode := r.URL.Query().Get("user_code")
+	page := devicePage{Providers: h.providers.All(), ReturnTo: "/device"}
+	if userCode != "" {
+		page.ReturnTo += "?user_code=" + url.QueryEscape(userCode)
+	}
+
+	claims, _, signedIn := h.cookieSession(r)
+	page.SignedIn = signedIn
+	if signedIn {
+		token, err := utils.CSRFToken(claims.SessionID)
+		if err != nil {
+			log.Println("❌ Failed to derive CSRF token:", err)
+			http.Error(w, "Failed to render template", http.StatusInternalServerError)
+			return
+		}
+		page.CSRFToken = token
+	}
+	if signedIn && userCode != "" {
+		if grant, ok := h.devices.Lookup(userCode); ok {
+			page.UserCode = formatUserCode(grant.userCode)
+			page.ClientIP = grant.clientIP
+		} else {
+			page.Error = "This code is invalid or has expired. Check the code shown on your device."
+		}
+	}
+	renderDevicePage(w, http.StatusOK, page)
+}
+
+// DeviceDecision records the signed-in user's approval or denial of a device code.
+func (h *Handler) DeviceDecision(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := h.cookieSession(r)
+	if !ok {
+		http.Redirect(w, r, "/device", http.StatusSeeOther)
+		return
+	}
+	if !validCSRF(r, claims) {
+		log.Println("❌ CSRF check failed for device approval")
+		renderError(w, http.StatusForbidden, "Request expired", "The approval form could not be verified. Please reload the page and try again.")
+		return
+	}
+
+	approve := r.FormValue("action") == "approve"
+	if !h.devices.Decide(r.FormValue("user_code"), userID, approve) {
+		renderDevicePage(w, http.StatusBadRequest, devicePage{
+			SignedIn: true,
+			Error:    "This code is invalid or has expired. Check the code shown on your device.",
+		})
+		return
+	}
+
+	outcome := audit.Denied
+	if approve {
+		outcome = audit.Success
+	}
+	h.recordAudit(r, audit.Entry{ActorID: userID, Action: audit.ActionDeviceApprove, Target: normalizeUserCode(r.FormValue("user_code")), Outcome: outcome})
+
+	page := devicePage{SignedIn: true, Done: "Access denied. You can close this window."}
+	if approve {
+		log.Println("✅ Device code approved by user", userID)
+		page.Done = "Your device is now signed in. You can close this window and return to it."
+	}
+	renderDevicePage(w, http.StatusOK, page)
+}
+
+// renderDevicePage renders templates/device.html with the given status code.
+func renderDevicePage(w http.ResponseWriter, status int, page devicePage) {
+	tmplPath := filepath.Join("templates", "device.html")
+	tmpl, err := template.ParseFiles(tmplPath)
+	if err != nil {
+		http.Error(w, "Failed to load template", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, page); err != nil {
+		log.Println("❌ Failed to render device template:", err)
+	}
+}
+
+// publicURL returns the externally visible base URL of the server, from PUBLIC_URL
+// or else the request's host.
+func publicURL(r *http.Request) string {
+	if base := utils.GetEnv("PUBLIC_URL", ""); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
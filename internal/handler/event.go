@@ -2,12 +2,19 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
-	"golang.org/x/oauth2"
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"google.golang.org/api/calendar/v3"
 )
 
@@ -19,7 +26,7 @@ Step-by-Step Process:
 4. Convert the received date-time format into RFC3339 format.
 5. Create a new event structure and set necessary details.
 6. Insert the event into the Google Calendar using the API.
-7. Store the event details in the PostgreSQL database.
+7. Store the event details in the PostgreSQL database, deleting the Google event again if that fails.
 8. Return a success or failure response.
 */
 
@@ -27,6 +34,11 @@ Step-by-Step Process:
 func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("📌 In CreateEvent handler")
 
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
 	// Step 1: Decode JSON request body
 	var request struct {
 		Title       string `json:"summary"`
@@ -39,7 +51,8 @@ func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 			DateTime string `json:"dateTime"`
 			TimeZone string `json:"timeZone"`
 		} `json:"end"`
-		Attendees []string `json:"attendees"` // List of attendee emails
+		Attendees  []string `json:"attendees"`  // List of attendee emails
+		CalendarID string   `json:"calendarId"` // Calendar to create the event in; defaults to the user's default calendar
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -48,21 +61,23 @@ func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Step 2: Retrieve OAuth token from session or database
-	token, _, err := h.getUserTokenFromDB(r) // Implement this function to get the token
+	// Step 2: Find the linked Google accounts that may write events
+	identities, err := h.calendarIdentities(r, accessWrite)
 	if err != nil {
 		log.Println("[ERROR] Failed to retrieve user token:", err)
-		http.Error(w, "Failed to retrieve token", http.StatusUnauthorized)
+		writeTokenError(w, err, http.StatusUnauthorized, "Failed to retrieve token")
 		return
 	}
-	fmt.Println("📌 Token Retrieved Successfully")
 
-	// Step 3: Create Google Calendar service client
-	client := h.oauthConfig.Client(oauth2.NoContext, token)
-	service, err := calendar.New(client)
+	// Step 3: Create a Google Calendar service client for the first account that can see the calendar
+	calendarID := request.CalendarID
+	if calendarID == "" {
+		calendarID = defaultCalendar(&current.User)
+	}
+	identity, service, err := h.calendarAccount(r, identities, calendarID)
 	if err != nil {
 		log.Println("[ERROR] Failed to create calendar service:", err)
-		http.Error(w, "Failed to create calendar service", http.StatusInternalServerError)
+		writeCalendarError(w, err, "Failed to create calendar service")
 		return
 	}
 	fmt.Println("📌 Google Calendar Service Created")
@@ -73,8 +88,9 @@ func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 		eventAttendees = append(eventAttendees, &calendar.EventAttendee{Email: email})
 	}
 
-	// Step 5: Create the event object
+	// Step 5: Create the event object, with an ID chosen by us so a failed create can be undone
 	event := &calendar.Event{
+		Id:          newEventID(),
 		Summary:     request.Title,
 		Description: request.Description,
 		Start: &calendar.EventDateTime{
@@ -96,87 +112,603 @@ func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	log.Printf("    - Attendees: %v", request.Attendees)
 
 	// Step 7: Insert event into Google Calendar
-	createdEvent, err := service.Events.Insert("primary", event).Do()
+	createdEvent, err := service.Events.Insert(calendarID, event).Do()
 	if err != nil {
 		log.Println("[ERROR] Failed to create event in Google Calendar:", err)
-		http.Error(w, "Failed to create event", http.StatusInternalServerError)
+		// The event may exist if only the response was lost, e.g. on a timeout
+		if insertMayHaveSucceeded(err) {
+			if err := removeEvent(r.Context(), service, calendarID, event.Id); err != nil {
+				log.Println("[ERROR] Failed to remove possibly created event:", err)
+			}
+		}
+		h.recordAudit(r, audit.Entry{ActorEmail: identity.Email, Action: audit.ActionEventCreate, Target: event.Id, Outcome: audit.Failure, Detail: err.Error()})
+		writeTokenError(w, err, http.StatusInternalServerError, "Failed to create event")
+		return
+	}
+
+	// Step 8: Store the event in the database; if that keeps failing, delete it from
+	// Google Calendar again so the two never disagree and the client can safely retry
+	meeting := meetingFromEvent(current.ID(), identity, calendarID, createdEvent)
+	if err := h.storeMeeting(r.Context(), &meeting); err != nil {
+		log.Println("[ERROR] Failed to store meeting:", err)
+		detail := "stored meeting failed, event removed: " + err.Error()
+		if removeErr := removeEvent(r.Context(), service, calendarID, createdEvent.Id); removeErr != nil {
+			log.Println("[ERROR] Failed to remove event after storing it failed:", removeErr)
+			detail = "stored meeting failed and event could not be removed from Google Calendar: " + removeErr.Error()
+		}
+		h.recordAudit(r, audit.Entry{ActorEmail: identity.Email, Action: audit.ActionEventCreate, Target: createdEvent.Id, Outcome: audit.Failure, Detail: detail})
+		http.Error(w, "Failed to save event", http.StatusInternalServerError)
 		return
 	}
 
-	// Step 8: Respond with success message
+	h.recordAudit(r, audit.Entry{ActorEmail: identity.Email, Action: audit.ActionEventCreate, Target: createdEvent.Id, Outcome: audit.Success})
+
+	// Step 9: Respond with success message
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Event created successfully", "event_id": createdEvent.Id})
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Event created successfully", "event_id": createdEvent.Id, "meeting_id": meeting.ID, "calendar": calendarID})
 
 	fmt.Println("✅ Event Created Successfully!")
 }
 
-// ListEvents fetches upcoming meetings from both Google Calendar and the database
+// ListEvents fetches meetings from the selected calendars of every linked Google
+// account and merges them by start time. The calendars are named by calendarId and
+// default to the user's default calendar; "primary" is read in every account, any
+// other calendar in the first account that can see it. Accounts that fail are
+// reported in unavailable_accounts and calendars no account can see in
+// unknown_calendars; the request only fails if nothing could be read. With
+// source=local the meetings created through this app in the same calendars are
+// served from the database instead.
+//
+// The range and filter are set by timeMin, timeMax and q (see parseEventQuery).
+// Each page holds up to pageSize events per account; if any account has more, the
+// response carries a next_cursor to pass as cursor for the following page.
 func (h *Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
 	log.Println("📌 In ListEvents handler")
 
-	// Step 1: Retrieve OAuth token from database
-	token, userEmail, err := h.getUserTokenFromDB(r)
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	query, err := parseEventQuery(r)
 	if err != nil {
-		log.Println("[ERROR] Failed to retrieve user token:", err)
-		http.Error(w, "Failed to retrieve token", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusBadRequest, apiError{Error: "invalid_request", Message: err.Error()})
 		return
 	}
 
-	// Step 2: Create Google Calendar service client
-	client := h.oauthConfig.Client(oauth2.NoContext, token)
-	service, err := calendar.New(client)
+	// Both sources read the user's default calendar unless calendars are named
+	if len(query.Calendars) == 0 {
+		query.Calendars = []string{defaultCalendar(&current.User)}
+	}
+
+	if query.Local {
+		meetings, next, err := h.localEvents(r.Context(), current.ID(), query)
+		if err != nil {
+			log.Println("[ERROR] Failed to fetch meetings from the database:", err)
+			http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"events": meetings, "next_cursor": next})
+		return
+	}
+
+	// Step 1: Find the linked Google accounts that may read events
+	identities, err := h.calendarIdentities(r, accessRead)
 	if err != nil {
-		log.Println("[ERROR] Failed to create calendar service:", err)
-		http.Error(w, "Failed to create calendar service", http.StatusInternalServerError)
+		log.Println("[ERROR] Failed to retrieve user token:", err)
+		writeTokenError(w, err, http.StatusUnauthorized, "Failed to retrieve token")
 		return
 	}
+	calendarIDs := query.Calendars
+
+	// Step 2: Fetch a page of meetings from each selected calendar; on later pages
+	// only the account and calendar pairs that had more events are read
+	meetings := []map[string]interface{}{}
+	unavailable := []map[string]string{}
+	unknown := []string{}
+	nextTokens := map[string]string{}
+	queried := 0
+	var firstErr error
+	for _, calendarID := range calendarIDs {
+		found := false
+		for i := range identities {
+			key := identities[i].ID.String() + " " + calendarID
+			pageToken, ok := query.Tokens[key]
+			if query.Tokens != nil && !ok {
+				continue
+			}
+
+			items, next, err := h.eventsPage(r, &identities[i], calendarID, query, pageToken)
+			if calendarID != primaryCalendar && isNotFound(err) {
+				continue // Not one of this account's calendars
+			}
+			found = true
+			queried++
+			if err != nil {
+				log.Printf("[ERROR] Failed to fetch events of %s for %s: %v", calendarID, identities[i].Email, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				unavailable = append(unavailable, map[string]string{
+					"identity_id": identities[i].ID.String(),
+					"email":       identities[i].Email,
+					"calendar":    calendarID,
+				})
+				continue
+			}
+			meetings = append(meetings, items...)
+			if next != "" {
+				nextTokens[key] = next
+			}
+
+			// Calendars shared with several accounts are only read once
+			if calendarID != primaryCalendar {
+				break
+			}
+		}
+		if !found && query.Tokens == nil {
+			unknown = append(unknown, calendarID)
+		}
+	}
+	if queried > 0 && len(unavailable) == queried {
+		writeTokenError(w, firstErr, http.StatusInternalServerError, "Failed to fetch Google Calendar events")
+		return
+	}
+
+	// Step 3: Merge the accounts' events by start time
+	sort.SliceStable(meetings, func(i, j int) bool {
+		return meetings[i]["start_time"].(time.Time).Before(meetings[j]["start_time"].(time.Time))
+	})
+
+	nextCursor := ""
+	if len(nextTokens) > 0 {
+		next := *query
+		next.Tokens = nextTokens
+		nextCursor = encodeCursor(&next)
+	}
+
+	// Step 4: Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":               meetings,
+		"unavailable_accounts": unavailable,
+		"unknown_calendars":    unknown,
+		"next_cursor":          nextCursor,
+	})
+
+	log.Println("✅ Events Listed Successfully!")
+}
 
-	// Step 3: Fetch upcoming meetings from Google Calendar
-	now := time.Now().Format(time.RFC3339)
-	weekLater := time.Now().AddDate(0, 0, 7).Format(time.RFC3339)
+// eventsPage returns one page of the query's events from one of the identity's
+// calendars, and the token of the next page if there is one.
+func (h *Handler) eventsPage(r *http.Request, identity *models.Identity, calendarID string, query *eventQuery, pageToken string) ([]map[string]interface{}, string, error) {
+	service, err := h.calendarService(r, identity)
+	if err != nil {
+		return nil, "", err
+	}
 
-	events, err := service.Events.List("primary").
+	call := service.Events.List(calendarID).
 		ShowDeleted(false).
 		SingleEvents(true).
-		TimeMin(now).
-		TimeMax(weekLater).
+		TimeMin(query.TimeMin.Format(time.RFC3339)).
+		TimeMax(query.TimeMax.Format(time.RFC3339)).
 		OrderBy("startTime").
-		Do()
+		MaxResults(int64(query.PageSize)).
+		Context(r.Context())
+	if query.Q != "" {
+		call = call.Q(query.Q)
+	}
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	events, err := call.Do()
 	if err != nil {
-		log.Println("[ERROR] Failed to fetch events from Google Calendar:", err)
-		http.Error(w, "Failed to fetch Google Calendar events", http.StatusInternalServerError)
-		return
+		return nil, "", err
 	}
 
-	// Step 4: Process Google Calendar events
-	var googleMeetings []map[string]interface{}
+	var meetings []map[string]interface{}
 	for _, item := range events.Items {
-		startTime, _ := time.Parse(time.RFC3339, item.Start.DateTime)
-		endTime, _ := time.Parse(time.RFC3339, item.End.DateTime)
+		meeting := eventItem(identity, item)
+		meeting["calendar"] = calendarID
+		meetings = append(meetings, meeting)
+	}
+	return meetings, events.NextPageToken, nil
+}
+
+// eventItem converts a Google Calendar event read through the identity into the JSON
+// shape used by the event endpoints.
+func eventItem(identity *models.Identity, item *calendar.Event) map[string]interface{} {
+	var startTime, endTime time.Time
+	if item.Start != nil {
+		startTime, _ = time.Parse(time.RFC3339, item.Start.DateTime)
+	}
+	if item.End != nil {
+		endTime, _ = time.Parse(time.RFC3339, item.End.DateTime)
+	}
+
+	attendees := []string{}
+	if item.Attendees != nil {
+		for _, a := range item.Attendees {
+			attendees = append(attendees, a.Email)
+		}
+	}
+
+	return map[string]interface{}{
+		"title":       item.Summary,
+		"description": item.Description,
+		"start_time":  startTime,
+		"end_time":    endTime,
+		"event_id":    item.Id,
+		"attendees":   attendees,
+		"created_by":  identity.Email,
+		"account":     identity.Email, // Linked account the event was read from
+	}
+}
+
+// sendUpdatesValues are the accepted values of the sendUpdates parameter, which
+// controls whether Google emails attendees about a change.
+var sendUpdatesValues = map[string]bool{"all": true, "externalOnly": true, "none": true}
+
+// errEventNotFound is returned when none of the user's accounts can see an event.
+var errEventNotFound = errors.New("event not found")
+
+// eventRef is an event found in one of the signed-in user's linked Google accounts.
+type eventRef struct {
+	userID     uuid.UUID // Signed-in user who looked the event up
+	identity   *models.Identity
+	service    *calendar.Service
+	calendarID string
+	event      *calendar.Event
+	owned      bool // Whether the user created the event through this app
+}
+
+// canModify reports whether the user may change or delete the event: they created it
+// through this app, the linked account is its organizer, or the organizer lets guests
+// modify it.
+func (ref *eventRef) canModify() bool {
+	if ref.owned || ref.event.GuestsCanModify {
+		return true
+	}
+	return ref.event.Organizer != nil && ref.event.Organizer.Self
+}
+
+// findEvent looks the event up in the signed-in user's Google accounts that have the
+// access level, starting with the account it was created with if it was created
+// through this app. It is looked for in the calendar named by the calendarId query
+// parameter, else in the calendar it was created in, else in the user's default
+// calendar and the primary one. Cancelled events count as not found.
+func (h *Handler) findEvent(r *http.Request, eventID string, access calendarAccess) (*eventRef, error) {
+	current, ok := CurrentUserFrom(r.Context())
+	if !ok {
+		return nil, errors.New("user not authenticated")
+	}
+	identities, err := h.calendarIdentities(r, access)
+	if err != nil {
+		return nil, err
+	}
 
-		attendees := []string{}
-		if item.Attendees != nil {
-			for _, a := range item.Attendees {
-				attendees = append(attendees, a.Email)
+	var meeting models.Meeting
+	owned := h.DB.Where("user_id = ? AND event_id = ?", current.ID(), eventID).First(&meeting).Error == nil
+	if owned {
+		sort.SliceStable(identities, func(i, j int) bool {
+			return identities[i].ID == meeting.IdentityID && identities[j].ID != meeting.IdentityID
+		})
+	}
+
+	requested := r.URL.Query().Get("calendarId")
+	var firstErr error
+	for i := range identities {
+		ownedHere := owned && identities[i].ID == meeting.IdentityID
+
+		var candidates []string
+		switch {
+		case requested != "":
+			candidates = []string{requested}
+		case ownedHere:
+			candidates = []string{meeting.CalendarID}
+		default:
+			candidates = []string{defaultCalendar(&current.User)}
+			if candidates[0] != primaryCalendar {
+				candidates = append(candidates, primaryCalendar)
 			}
 		}
 
-		googleMeetings = append(googleMeetings, map[string]interface{}{
-			"title":       item.Summary,
-			"description": item.Description,
-			"start_time":  startTime,
-			"end_time":    endTime,
-			"event_id":    item.Id,
-			"attendees":   attendees,
-			"created_by":  userEmail,
+		service, err := h.calendarService(r, &identities[i])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, calendarID := range candidates {
+			event, err := service.Events.Get(calendarID, eventID).Context(r.Context()).Do()
+			if err != nil {
+				if !isNotFound(err) && firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			if event.Status == "cancelled" {
+				continue
+			}
+
+			return &eventRef{
+				userID:     current.ID(),
+				identity:   &identities[i],
+				service:    service,
+				calendarID: calendarID,
+				event:      event,
+				owned:      ownedHere,
+			}, nil
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, errEventNotFound
+}
+
+// writeEventError reports a failure to find or change an event.
+func writeEventError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, errEventNotFound) || isNotFound(err) {
+		writeJSONError(w, http.StatusNotFound, apiError{
+			Error:   "event_not_found",
+			Message: "The event does not exist in any of your linked Google accounts.",
+		})
+		return
+	}
+	writeTokenError(w, err, http.StatusInternalServerError, message)
+}
+
+// GetEvent returns a single event from the user's linked Google accounts, with its
+// Google etag in the ETag header for use in If-Match when updating it.
+func (h *Handler) GetEvent(w http.ResponseWriter, r *http.Request) {
+	ref, err := h.findEvent(r, mux.Vars(r)["id"], accessRead)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch event:", err)
+		writeEventError(w, err, "Failed to fetch event")
+		return
+	}
+
+	// Keep the stored copy current, since it is what source=local serves
+	if err := h.syncMeeting(r.Context(), ref); err != nil {
+		log.Println("⚠️ Failed to update stored meeting:", err)
+	}
+
+	writeEvent(w, http.StatusOK, ref)
+}
+
+// UpdateEvent changes the fields present in the request body on the Google event and
+// on its stored meeting. The optional sendUpdates query parameter (all, externalOnly
+// or none) controls whether attendees are notified.
+//
+// The If-Match header must carry the ETag from GetEvent, so that a change made by
+// someone else in the meantime is not overwritten. It is also forwarded to Google;
+// on a mismatch the response is 412 with the current event.
+func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		writeJSONError(w, http.StatusPreconditionRequired, apiError{
+			Error:   "precondition_required",
+			Message: "Send the event's ETag in the If-Match header.",
+		})
+		return
+	}
+
+	var request struct {
+		Title       *string `json:"summary"`
+		Description *string `json:"description"`
+		Start       *struct {
+			DateTime string `json:"dateTime"`
+			TimeZone string `json:"timeZone"`
+		} `json:"start"`
+		End *struct {
+			DateTime string `json:"dateTime"`
+			TimeZone string `json:"timeZone"`
+		} `json:"end"`
+		Attendees *[]string `json:"attendees"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	sendUpdates, ok := sendUpdatesParam(w, r)
+	if !ok {
+		return
+	}
+
+	eventID := mux.Vars(r)["id"]
+	ref, ok := h.modifiableEvent(w, r, eventID, audit.ActionEventUpdate)
+	if !ok {
+		return
+	}
+	if !etagMatches(ifMatch, ref.event.Etag) {
+		h.writeEventConflict(w, r, ref)
+		return
+	}
+
+	// Only send the fields being changed so that everything else is left untouched
+	patch := &calendar.Event{}
+	if request.Title != nil {
+		patch.Summary = *request.Title
+		patch.ForceSendFields = append(patch.ForceSendFields, "Summary")
+	}
+	if request.Description != nil {
+		patch.Description = *request.Description
+		patch.ForceSendFields = append(patch.ForceSendFields, "Description")
+	}
+	if request.Start != nil {
+		patch.Start = &calendar.EventDateTime{DateTime: request.Start.DateTime, TimeZone: request.Start.TimeZone}
+	}
+	if request.End != nil {
+		patch.End = &calendar.EventDateTime{DateTime: request.End.DateTime, TimeZone: request.End.TimeZone}
+	}
+	if request.Attendees != nil {
+		patch.Attendees = []*calendar.EventAttendee{}
+		for _, email := range *request.Attendees {
+			patch.Attendees = append(patch.Attendees, &calendar.EventAttendee{Email: email})
+		}
+		patch.ForceSendFields = append(patch.ForceSendFields, "Attendees")
+	}
+
+	call := ref.service.Events.Patch(ref.calendarID, eventID, patch).Context(r.Context())
+	if sendUpdates != "" {
+		call = call.SendUpdates(sendUpdates)
+	}
+	// Guard against a change landing between our read and the patch
+	call.Header().Set("If-Match", ref.event.Etag)
+	updated, err := call.Do()
+	if isPreconditionFailed(err) {
+		h.writeEventConflict(w, r, ref)
+		return
+	}
+	if err != nil {
+		log.Println("[ERROR] Failed to update event in Google Calendar:", err)
+		h.recordAudit(r, audit.Entry{Action: audit.ActionEventUpdate, Target: eventID, Outcome: audit.Failure, Detail: err.Error()})
+		writeEventError(w, err, "Failed to update event")
+		return
+	}
+	ref.event = updated
+
+	// Google is the source of truth; a stale meeting is corrected on the next change
+	if err := h.syncMeeting(r.Context(), ref); err != nil {
+		log.Println("⚠️ Failed to update stored meeting:", err)
+	}
+
+	h.recordAudit(r, audit.Entry{Action: audit.ActionEventUpdate, Target: eventID, Outcome: audit.Success})
+	writeEvent(w, http.StatusOK, ref)
+}
+
+// DeleteEvent deletes the event from Google Calendar and removes its stored meeting.
+// The optional sendUpdates query parameter controls whether attendees are notified.
+// An If-Match header is optional; if sent, it is checked like in UpdateEvent.
+func (h *Handler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
+	sendUpdates, ok := sendUpdatesParam(w, r)
+	if !ok {
+		return
+	}
+
+	eventID := mux.Vars(r)["id"]
+	ref, ok := h.modifiableEvent(w, r, eventID, audit.ActionEventDelete)
+	if !ok {
+		return
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !etagMatches(ifMatch, ref.event.Etag) {
+		h.writeEventConflict(w, r, ref)
+		return
+	}
+
+	call := ref.service.Events.Delete(ref.calendarID, eventID).Context(r.Context())
+	if sendUpdates != "" {
+		call = call.SendUpdates(sendUpdates)
+	}
+	if ifMatch != "" {
+		call.Header().Set("If-Match", ref.event.Etag)
+	}
+	err := call.Do()
+	if isPreconditionFailed(err) {
+		h.writeEventConflict(w, r, ref)
+		return
+	}
+	if err != nil && !isNotFound(err) {
+		log.Println("[ERROR] Failed to delete event from Google Calendar:", err)
+		h.recordAudit(r, audit.Entry{Action: audit.ActionEventDelete, Target: eventID, Outcome: audit.Failure, Detail: err.Error()})
+		writeEventError(w, err, "Failed to delete event")
+		return
+	}
+
+	if err := h.ownMeeting(r.Context(), ref).Delete(&models.Meeting{}).Error; err != nil {
+		log.Println("⚠️ Failed to delete stored meeting:", err)
+	}
+
+	h.recordAudit(r, audit.Entry{Action: audit.ActionEventDelete, Target: eventID, Outcome: audit.Success})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// modifiableEvent finds an event the user is about to change and checks that they
+// may. It writes the error response and returns false otherwise.
+func (h *Handler) modifiableEvent(w http.ResponseWriter, r *http.Request, eventID, action string) (*eventRef, bool) {
+	ref, err := h.findEvent(r, eventID, accessWrite)
+	if err != nil {
+		log.Println("[ERROR] Failed to fetch event:", err)
+		writeEventError(w, err, "Failed to fetch event")
+		return nil, false
+	}
+	if !ref.canModify() {
+		log.Printf("❌ Event %s is not modifiable by %s", eventID, ref.identity.Email)
+		h.recordAudit(r, audit.Entry{Action: action, Target: eventID, Outcome: audit.Denied, Detail: "not the organizer"})
+		writeJSONError(w, http.StatusForbidden, apiError{
+			Error:   "not_event_organizer",
+			Message: "Only the organizer can change this event.",
+		})
+		return nil, false
+	}
+	return ref, true
+}
+
+// sendUpdatesParam reads the optional sendUpdates query parameter. It writes a 400
+// response and returns false if the value is not one Google accepts.
+func sendUpdatesParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	value := r.URL.Query().Get("sendUpdates")
+	if value != "" && !sendUpdatesValues[value] {
+		writeJSONError(w, http.StatusBadRequest, apiError{
+			Error:   "invalid_request",
+			Message: "sendUpdates must be one of all, externalOnly or none.",
 		})
+		return "", false
+	}
+	return value, true
+}
+
+// eventView is the JSON representation of a single event.
+func eventView(ref *eventRef) map[string]interface{} {
+	view := eventItem(ref.identity, ref.event)
+	view["calendar"] = ref.calendarID
+	if ref.event.Organizer != nil {
+		view["organizer"] = ref.event.Organizer.Email
 	}
+	view["can_modify"] = ref.canModify()
+	return view
+}
 
-	// Step 5: Send response
+// writeEvent writes the event with its etag in the ETag header.
+func writeEvent(w http.ResponseWriter, status int, ref *eventRef) {
+	if ref.event.Etag != "" {
+		w.Header().Set("ETag", ref.event.Etag)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"events": googleMeetings,
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(eventView(ref))
+}
+
+// writeEventConflict responds 412 with the current version of an event whose etag
+// did not match If-Match, so the client can merge its change and retry.
+func (h *Handler) writeEventConflict(w http.ResponseWriter, r *http.Request, ref *eventRef) {
+	// The event may have changed again since it was read
+	if current, err := ref.service.Events.Get(ref.calendarID, ref.event.Id).Context(r.Context()).Do(); err == nil {
+		ref.event = current
+	}
+
+	log.Printf("❌ Event %s was modified concurrently", ref.event.Id)
+	if ref.event.Etag != "" {
+		w.Header().Set("ETag", ref.event.Etag)
+	}
+	writeJSONError(w, http.StatusPreconditionFailed, apiError{
+		Error:   "precondition_failed",
+		Message: "The event was changed by someone else. Review the current version and try again.",
+		Current: eventView(ref),
 	})
+}
 
-	log.Println("✅ Events Listed Successfully!")
+// etagMatches reports whether an If-Match header, "*" or a comma-separated list of
+// etags, matches the etag.
+func etagMatches(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults and bounds of the ListEvents query.
+const (
+	defaultEventWindow   = 7 * 24 * time.Hour // Range listed when timeMax is not given
+	defaultEventPageSize = 100
+	maxEventPageSize     = 250
+	maxCursorLength      = 64 << 10 // Longest cursor accepted, bounding the work spent decoding one
+)
+
+// eventQuery is the range, filter and position of a ListEvents request. It is
+// encoded in the cursor so that later pages continue the same query.
+type eventQuery struct {
+	Local     bool              `json:"local,omitempty"`     // Read stored meetings instead of Google Calendar
+	TimeMin   time.Time         `json:"min"`                 // Earliest end time of listed events
+	TimeMax   time.Time         `json:"max"`                 // Latest start time of listed events
+	Q         string            `json:"q,omitempty"`         // Free text search
+	Calendars []string          `json:"calendars,omitempty"` // Calendars named by calendarId, else the user's default
+	PageSize  int               `json:"size"`                // Events per page, per account
+	Tokens    map[string]string `json:"tokens,omitempty"`    // Google page token of each account and calendar with more events
+	Offset    int               `json:"offset,omitempty"`    // Meetings already listed, for source=local
+}
+
+// parseEventQuery reads the ListEvents query parameters: timeMin and timeMax as
+// RFC3339 (default now and a week later), q, calendarId, pageSize and source. A
+// cursor from a previous response replaces all of them.
+func parseEventQuery(r *http.Request) (*eventQuery, error) {
+	params := r.URL.Query()
+	if cursor := params.Get("cursor"); cursor != "" {
+		return decodeCursor(cursor)
+	}
+
+	query := &eventQuery{
+		Local:     params.Get("source") == "local",
+		TimeMin:   time.Now(),
+		Q:         params.Get("q"),
+		Calendars: calendarParams(r),
+		PageSize:  defaultEventPageSize,
+	}
+	if value := params.Get("timeMin"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("timeMin must be an RFC3339 timestamp")
+		}
+		query.TimeMin = t
+	}
+	query.TimeMax = query.TimeMin.Add(defaultEventWindow)
+	if value := params.Get("timeMax"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("timeMax must be an RFC3339 timestamp")
+		}
+		query.TimeMax = t
+	}
+	if !query.TimeMax.After(query.TimeMin) {
+		return nil, errors.New("timeMax must be after timeMin")
+	}
+	if value := params.Get("pageSize"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxEventPageSize {
+			return nil, errors.New("pageSize must be between 1 and " + strconv.Itoa(maxEventPageSize))
+		}
+		query.PageSize = n
+	}
+	return query, nil
+}
+
+// encodeCursor returns the opaque cursor for the query.
+func encodeCursor(query *eventQuery) string {
+	b, _ := json.Marshal(query)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses a cursor made by encodeCursor.
+func decodeCursor(cursor string) (*eventQuery, error) {
+	errInvalid := errors.New("cursor is invalid")
+	if len(cursor) > maxCursorLength {
+		return nil, errInvalid
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalid
+	}
+	var query eventQuery
+	if err := json.Unmarshal(b, &query); err != nil {
+		return nil, errInvalid
+	}
+	if query.PageSize < 1 || query.PageSize > maxEventPageSize || query.Offset < 0 || !query.TimeMax.After(query.TimeMin) {
+		return nil, errInvalid
+	}
+	return &query, nil
+}
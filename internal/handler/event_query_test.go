@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEventQuery(t *testing.T) {
+	min := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    *eventQuery
+		wantErr bool
+	}{
+		{
+			name:  "explicit range",
+			query: "timeMin=2026-03-01T09:00:00Z&timeMax=2026-03-02T09:00:00Z",
+			want:  &eventQuery{TimeMin: min, TimeMax: min.Add(24 * time.Hour), PageSize: defaultEventPageSize},
+		},
+		{
+			name:  "default window after timeMin",
+			query: "timeMin=2026-03-01T09:00:00Z",
+			want:  &eventQuery{TimeMin: min, TimeMax: min.Add(defaultEventWindow), PageSize: defaultEventPageSize},
+		},
+		{
+			name:  "filters and page size",
+			query: "timeMin=2026-03-01T09:00:00Z&q=standup&calendarId=a@example.com,+b@example.com&calendarId=c&pageSize=250&source=local",
+			want: &eventQuery{
+				Local:     true,
+				TimeMin:   min,
+				TimeMax:   min.Add(defaultEventWindow),
+				Q:         "standup",
+				Calendars: []string{"a@example.com", "b@example.com", "c"},
+				PageSize:  maxEventPageSize,
+			},
+		},
+		{name: "invalid timeMin", query: "timeMin=2026-03-01", wantErr: true},
+		{name: "invalid timeMax", query: "timeMax=tomorrow", wantErr: true},
+		{name: "timeMax before timeMin", query: "timeMin=2026-03-02T09:00:00Z&timeMax=2026-03-01T09:00:00Z", wantErr: true},
+		{name: "empty range", query: "timeMin=2026-03-01T09:00:00Z&timeMax=2026-03-01T09:00:00Z", wantErr: true},
+		{name: "page size zero", query: "pageSize=0", wantErr: true},
+		{name: "page size too large", query: "pageSize=251", wantErr: true},
+		{name: "page size not a number", query: "pageSize=ten", wantErr: true},
+		{name: "invalid cursor", query: "cursor=not-a-cursor", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEventQuery(httptest.NewRequest(http.MethodGet, "/api/events?"+tt.query, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEventQuery error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseEventQuery = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEventQueryDefaults(t *testing.T) {
+	before := time.Now()
+	got, err := parseEventQuery(httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	if err != nil {
+		t.Fatalf("parseEventQuery: %v", err)
+	}
+	if got.TimeMin.Before(before) || got.TimeMin.After(time.Now()) {
+		t.Errorf("TimeMin = %s, want now", got.TimeMin)
+	}
+	if got.TimeMax.Sub(got.TimeMin) != defaultEventWindow {
+		t.Errorf("window = %s, want %s", got.TimeMax.Sub(got.TimeMin), defaultEventWindow)
+	}
+	if got.PageSize != defaultEventPageSize || got.Local || got.Q != "" || got.Calendars != nil {
+		t.Errorf("parseEventQuery = %+v, want the defaults", got)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	min := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	query := &eventQuery{
+		TimeMin:   min,
+		TimeMax:   min.Add(time.Hour),
+		Q:         "standup",
+		Calendars: []string{"primary", "team@group.calendar.google.com"},
+		PageSize:  50,
+		Tokens:    map[string]string{"3f1c identity primary": "page-token"},
+	}
+
+	// A cursor replaces every other parameter of the request
+	r := httptest.NewRequest(http.MethodGet, "/api/events?pageSize=1&q=other&cursor="+encodeCursor(query), nil)
+	got, err := parseEventQuery(r)
+	if err != nil {
+		t.Fatalf("parseEventQuery: %v", err)
+	}
+	if !reflect.DeepEqual(got, query) {
+		t.Errorf("decoded cursor = %+v, want %+v", got, query)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	valid := `"min":"2026-03-01T09:00:00Z","max":"2026-03-02T09:00:00Z"`
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "%%%"},
+		{name: "not JSON", cursor: encode("size=10")},
+		{name: "JSON array", cursor: encode(`[1,2,3]`)},
+		{name: "trailing data", cursor: encode(`{` + valid + `,"size":10}{}`)},
+		{name: "missing page size", cursor: encode(`{` + valid + `}`)},
+		{name: "page size too large", cursor: encode(`{` + valid + `,"size":100000}`)},
+		{name: "negative page size", cursor: encode(`{` + valid + `,"size":-1}`)},
+		{name: "negative offset", cursor: encode(`{` + valid + `,"size":10,"offset":-10}`)},
+		{name: "missing range", cursor: encode(`{"size":10}`)},
+		{name: "inverted range", cursor: encode(`{"min":"2026-03-02T09:00:00Z","max":"2026-03-01T09:00:00Z","size":10}`)},
+		{name: "invalid time", cursor: encode(`{"min":"yesterday","max":"2026-03-01T09:00:00Z","size":10}`)},
+		{name: "wrong field type", cursor: encode(`{` + valid + `,"size":"10"}`)},
+		{name: "oversized", cursor: encode(`{` + valid + `,"size":10,"q":"` + strings.Repeat("x", maxCursorLength) + `"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor accepted %+v", got)
+			}
+		})
+	}
+}
@@ -1,46 +1,96 @@
 package handler
 
 import (
+	"context"
 	"os"
+	"time"
+
+	"google-calendar-api/internal/access"
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/internal/provider"
+	"google-calendar-api/internal/ratelimit"
+	"google-calendar-api/internal/session"
+	"google-calendar-api/internal/tokens"
+	"google-calendar-api/utils"
 
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"gorm.io/gorm"
 )
 
 // Handler struct manages OAuth2 authentication and database interactions.
 type Handler struct {
-	oauthConfig *oauth2.Config // OAuth2 configuration for Google authentication
-	DB          *gorm.DB      // Database connection instance
+	providers  *provider.Registry         // Configured identity providers
+	DB         *gorm.DB                   // Database connection instance
+	states     *stateStore                // Pending OAuth states awaiting their callback
+	sessionTTL time.Duration              // Lifetime of application-issued session tokens
+	sessions   session.Store              // Server-side record of login sessions
+	tokens     *tokens.Manager            // Refreshes and persists users' OAuth tokens
+	access     *access.Policy             // Decides which accounts may sign in
+	roles      roleConfig                 // Roles assigned at sign-in
+	devices    *deviceStore               // Pending device authorizations from command-line clients
+	audit      *audit.Logger              // Persistent audit trail of security-relevant actions
+	limiter    ratelimit.Limiter          // Token buckets for rate limiting
+	rateLimits map[string]ratelimit.Limit // Limit of each route group
 }
 
-// NewHandler initializes a new Handler with OAuth2 configuration and database connection.
+// NewHandler initializes a new Handler with the identity providers and database connection.
 //
-// It retrieves OAuth2 credentials from environment variables and sets up required scopes for authentication.
+// Providers are configured from environment variables (see provider.LoadFromEnv).
+// OIDC discovery runs once here; the resulting ID token verifiers and their signing
+// keys are shared by every request. Session lifetime is read from SESSION_TTL
+// (default 24h) and SESSION_STORE selects where sessions are kept ("postgres",
+// the default, or "memory"). The sign-in policy is read by access.PolicyFromEnv.
+// New users get DEFAULT_USER_ROLE (default "member"); addresses listed in
+// ADMIN_EMAILS are made administrators when they sign in. Device codes for
+// command-line sign-in last DEVICE_CODE_TTL (default 10m) and may be polled every
+// DEVICE_POLL_INTERVAL (default 5s). Rate limits are read from RATE_LIMIT_<GROUP>
+// and RATE_LIMIT_STORE selects where buckets are kept ("memory", the default, or
+// "postgres").
 //
 // Parameters:
 //   - db: A pointer to a gorm.DB instance for database interactions.
 //
 // Returns:
-//   - A pointer to a Handler instance with OAuth2 configuration and database connection.
-func NewHandler(db *gorm.DB) *Handler {
-	config := &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),        // Google OAuth Client ID
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),    // Google OAuth Client Secret
-		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),     // OAuth Redirect URL
-
-		Scopes: []string{
-			"openid",  // Required for obtaining ID Token
-			"email",   // Access to user's email
-			"profile", // Access to user's profile information
-			"https://www.googleapis.com/auth/calendar",         // Full access to Google Calendar
-			"https://www.googleapis.com/auth/calendar.events",  // Manage calendar events
-		},
-		Endpoint: google.Endpoint,
+//   - A pointer to a Handler instance with the providers and database connection.
+//   - An error if OIDC discovery fails or the configuration is invalid.
+func NewHandler(db *gorm.DB) (*Handler, error) {
+	providers, err := provider.LoadFromEnv(context.Background())
+	if err != nil {
+		return nil, err
 	}
 
-	return &Handler{
-		oauthConfig: config,
-		DB:          db,
+	sessions, err := session.NewStore(os.Getenv("SESSION_STORE"), db)
+	if err != nil {
+		return nil, err
+	}
+
+	limiter, err := ratelimit.NewLimiter(os.Getenv("RATE_LIMIT_STORE"), db)
+	if err != nil {
+		return nil, err
+	}
+	rateLimits, err := rateLimitsFromEnv()
+	if err != nil {
+		return nil, err
 	}
+
+	auditLog := audit.NewLogger(db)
+
+	return &Handler{
+		providers:  providers,
+		DB:         db,
+		states:     newStateStore(stateTTL),
+		sessionTTL: utils.GetEnvDuration("SESSION_TTL", defaultSessionTTL),
+		sessions:   sessions,
+		tokens:     tokens.NewManager(db, providers.OAuthConfig, auditLog),
+		access:     access.PolicyFromEnv(),
+		roles:      roleConfigFromEnv(),
+		devices:    newDeviceStore(utils.GetEnvDuration("DEVICE_CODE_TTL", defaultDeviceCodeTTL), utils.GetEnvDuration("DEVICE_POLL_INTERVAL", defaultDevicePollInterval)),
+		audit:      auditLog,
+		limiter:    limiter,
+		rateLimits: rateLimits,
+	}, nil
+}
+
+// Tokens returns the manager that refreshes and persists users' OAuth tokens.
+func (h *Handler) Tokens() *tokens.Manager {
+	return h.tokens
 }
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/internal/provider"
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errLastIdentity is returned when unlinking would leave a user with no way to sign in.
+var errLastIdentity = errors.New("cannot unlink the only linked account")
+
+// LinkIdentity starts linking another account from the named provider to the signed-in user.
+// The account is linked when the provider redirects back to Callback. The optional
+// identity parameter names an identity already linked to the user that must consent
+// again; its account is passed to the provider as a login hint.
+func (h *Handler) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	p, ok := h.providers.Get(mux.Vars(r)["provider"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Let the user pick an account other than the one they are signed in with, unless
+	// an already linked identity is being authorized again
+	opts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("prompt", "select_account consent")}
+	if hint, ok := h.loginHint(userID, p.Name, r.URL.Query().Get("identity")); ok {
+		opts = []oauth2.AuthCodeOption{hint, oauth2.SetAuthURLParam("prompt", "consent")}
+	}
+	attempt := authAttempt{purpose: purposeLink, userID: userID, returnTo: "/api/dashboard"}
+	h.startAuthorization(w, r, p, p.OAuth, attempt, opts...)
+}
+
+// completeLink links the provider account to the signed-in user, or refreshes its
+// tokens if it is already linked to them. An account linked to another user is refused.
+func (h *Handler) completeLink(w http.ResponseWriter, r *http.Request, attempt authAttempt, profile provider.Profile, token *oauth2.Token, grantedScopes string) {
+	p, _ := h.providers.Get(attempt.provider)
+
+	var identity models.Identity
+	err := h.DB.Where("provider = ? AND subject = ?", attempt.provider, profile.Subject).First(&identity).Error
+	switch {
+	case err == nil && identity.UserID != attempt.userID:
+		log.Println("❌ Account is already linked to another user:", profile.Email)
+		h.recordAudit(r, audit.Entry{ActorID: attempt.userID, ActorEmail: profile.Email, Action: audit.ActionLink, Target: attempt.provider, Outcome: audit.Denied, Detail: "account is linked to another user"})
+		renderError(w, http.StatusConflict, "Account already linked",
+			"This "+p.DisplayName+" account is already linked to another user. Unlink it there first.")
+		return
+	case err == nil:
+		err = h.DB.Model(&identity).Updates(identityUpdates(profile, token, grantedScopes)).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		identity = newIdentity(attempt.userID, p, profile, token, grantedScopes)
+		err = h.DB.Create(&identity).Error
+	}
+	if err != nil {
+		log.Println("❌ Error linking identity:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(r, audit.Entry{ActorID: attempt.userID, ActorEmail: profile.Email, Action: audit.ActionLink, Target: identity.ID.String(), Outcome: audit.Success})
+	log.Printf("✅ Linked %s account %s to user %s", p.DisplayName, profile.Email, attempt.userID)
+	http.Redirect(w, r, attempt.returnTo, http.StatusTemporaryRedirect)
+}
+
+// identityView is the JSON representation of a linked identity.
+type identityView struct {
+	ID            uuid.UUID `json:"id"`
+	Provider      string    `json:"provider"`
+	Email         string    `json:"email"`
+	GrantedScopes []string  `json:"granted_scopes"`
+	NeedsReauth   bool      `json:"needs_reauth"`
+	Connected     bool      `json:"connected"` // Whether tokens are stored for the identity
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// newIdentityView returns the JSON representation of the identity.
+func newIdentityView(identity models.Identity) identityView {
+	return identityView{
+		ID:            identity.ID,
+		Provider:      identity.Provider,
+		Email:         identity.Email,
+		GrantedScopes: splitScopes(identity.GrantedScopes),
+		NeedsReauth:   identity.NeedsReauth,
+		Connected:     identity.RefreshToken != "" || identity.AccessToken != "",
+		CreatedAt:     identity.CreatedAt,
+	}
+}
+
+// ListIdentities returns the provider accounts linked to the current user.
+func (h *Handler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	var identities []models.Identity
+	if err := h.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		log.Println("❌ Failed to list identities:", err)
+		http.Error(w, "Failed to list linked accounts", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]identityView, 0, len(identities))
+	for _, identity := range identities {
+		views = append(views, newIdentityView(identity))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"identities": views})
+}
+
+// UnlinkIdentity removes one of the current user's linked accounts and revokes its grant
+// at the provider on a best-effort basis. The last linked account cannot be removed.
+func (h *Handler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Linked account not found", http.StatusNotFound)
+		return
+	}
+
+	var removed models.Identity
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the user's identities so concurrent unlinks cannot remove the last two at once
+		var identities []models.Identity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).Find(&identities).Error; err != nil {
+			return err
+		}
+
+		found := false
+		for _, identity := range identities {
+			if identity.ID == id {
+				removed, found = identity, true
+			}
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		if len(identities) == 1 {
+			return errLastIdentity
+		}
+		return tx.Delete(&removed).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Linked account not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errLastIdentity):
+		http.Error(w, "Cannot unlink the only account you can sign in with", http.StatusConflict)
+		return
+	case err != nil:
+		log.Println("❌ Failed to unlink identity:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// The identity is gone either way, so a failed revocation is only logged
+	if err := h.revokeIdentity(r, &removed); err != nil {
+		log.Println("⚠️ Failed to revoke grant for unlinked account:", err)
+	}
+
+	h.recordAudit(r, audit.Entry{Action: audit.ActionIdentityUnlink, Target: removed.ID.String(), Outcome: audit.Success, Detail: removed.Provider + " " + removed.Email})
+	log.Printf("✅ Unlinked %s account %s from user %s", removed.Provider, removed.Email, userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeIdentity revokes the identity's grant at its provider. Revoking the refresh
+// token also revokes every access token issued from it. Identities without tokens or
+// whose provider has no revocation endpoint are skipped.
+func (h *Handler) revokeIdentity(r *http.Request, identity *models.Identity) error {
+	token := identity.RefreshToken.String()
+	if token == "" {
+		token = identity.AccessToken.String()
+	}
+	p, ok := h.providers.Get(identity.Provider)
+	if token == "" || !ok || p.RevocationURL == "" {
+		return nil
+	}
+	return h.revokeToken(r.Context(), p.RevocationURL, token)
+}
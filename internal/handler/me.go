@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+)
+
+// meResponse is the body of GET /api/me.
+type meResponse struct {
+	ID      uuid.UUID `json:"id"`
+	Email   string    `json:"email"`
+	Name    string    `json:"name"`
+	Picture string    `json:"picture"`
+	Role    string    `json:"role"`
+
+	Auth struct {
+		Method    string   `json:"method"`               // "session" or "access_token"
+		SessionID string   `json:"session_id,omitempty"` // Login session making the request
+		Scopes    []string `json:"scopes,omitempty"`     // Scopes of the personal access token
+	} `json:"auth"`
+
+	Calendar struct {
+		Connected     bool     `json:"connected"`      // A linked Google account has usable tokens
+		Read          bool     `json:"read"`           // Some linked Google account granted read access
+		Write         bool     `json:"write"`          // Some linked Google account granted write access
+		NeedsReauth   bool     `json:"needs_reauth"`   // Some linked Google account must grant access again
+		GrantedScopes []string `json:"granted_scopes"` // Union of the scopes granted by linked Google accounts
+	} `json:"calendar"`
+
+	Identities []identityView `json:"identities"`
+}
+
+// Me returns the signed-in user's profile, how the request was authenticated, the
+// Calendar access granted through their linked Google accounts and the connection
+// status of every linked account.
+func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var identities []models.Identity
+	if err := h.DB.Where("user_id = ?", current.ID()).Order("created_at").Find(&identities).Error; err != nil {
+		log.Println("❌ Failed to retrieve identities from DB:", err)
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	me := meResponse{
+		ID:         current.ID(),
+		Email:      current.User.Email,
+		Name:       current.User.Name,
+		Picture:    current.User.Picture,
+		Role:       current.User.Role,
+		Identities: make([]identityView, 0, len(identities)),
+	}
+
+	me.Auth.Method = "session"
+	me.Auth.SessionID = current.SessionID()
+	if current.AccessToken != nil {
+		me.Auth.Method = "access_token"
+		me.Auth.Scopes = current.AccessToken.ScopeList()
+	}
+
+	scopes := make(map[string]struct{})
+	for _, identity := range identities {
+		view := newIdentityView(identity)
+		me.Identities = append(me.Identities, view)
+		if identity.Provider != googleProviderName {
+			continue
+		}
+
+		if view.Connected && !identity.NeedsReauth {
+			me.Calendar.Connected = true
+		}
+		me.Calendar.NeedsReauth = me.Calendar.NeedsReauth || identity.NeedsReauth
+		me.Calendar.Read = me.Calendar.Read || hasCalendarAccess(identity.GrantedScopes, accessRead)
+		me.Calendar.Write = me.Calendar.Write || hasCalendarAccess(identity.GrantedScopes, accessWrite)
+		for _, scope := range view.GrantedScopes {
+			scopes[scope] = struct{}{}
+		}
+	}
+
+	me.Calendar.GrantedScopes = make([]string, 0, len(scopes))
+	for scope := range scopes {
+		me.Calendar.GrantedScopes = append(me.Calendar.GrantedScopes, scope)
+	}
+	sort.Strings(me.Calendar.GrantedScopes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(me)
+}
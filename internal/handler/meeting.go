@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"gorm.io/gorm"
+)
+
+// meetingWriteAttempts bounds how often storing a created event is tried before the
+// Google Calendar event is deleted again.
+const meetingWriteAttempts = 3
+
+// meetingRetryDelay is the wait before the second attempt; it doubles for each further attempt.
+const meetingRetryDelay = 100 * time.Millisecond
+
+// newEventID returns an event ID chosen by us rather than Google, so that the event
+// can be found and removed even if the response to the insert is lost. Google accepts
+// lowercase base32hex IDs, of which hex digits are a subset.
+func newEventID() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// meetingFromEvent builds the local record of an event created in the identity's calendar.
+func meetingFromEvent(userID uuid.UUID, identity *models.Identity, calendarID string, event *calendar.Event) models.Meeting {
+	startTime, _ := time.Parse(time.RFC3339, event.Start.DateTime)
+	endTime, _ := time.Parse(time.RFC3339, event.End.DateTime)
+
+	attendees := make([]string, 0, len(event.Attendees))
+	for _, a := range event.Attendees {
+		attendees = append(attendees, a.Email)
+	}
+
+	return models.Meeting{
+		UserID:      userID,
+		IdentityID:  identity.ID,
+		CalendarID:  calendarID,
+		Title:       event.Summary,
+		Description: event.Description,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		EventID:     event.Id,
+		ETag:        event.Etag,
+		Attendees:   strings.Join(attendees, ","),
+		CreatedBy:   identity.Email,
+	}
+}
+
+// storeMeeting saves a meeting, retrying with backoff so that a brief database
+// outage does not undo an event that was already created in Google Calendar.
+func (h *Handler) storeMeeting(ctx context.Context, meeting *models.Meeting) error {
+	delay := meetingRetryDelay
+	var err error
+	for attempt := 1; attempt <= meetingWriteAttempts; attempt++ {
+		if err = h.DB.WithContext(ctx).Create(meeting).Error; err == nil {
+			return nil
+		}
+		log.Printf("⚠️ Failed to store meeting (attempt %d/%d): %v", attempt, meetingWriteAttempts, err)
+		if attempt == meetingWriteAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return err
+}
+
+// removeEvent deletes an event from Google Calendar to compensate for a create that
+// could not be completed. An event that does not exist counts as removed.
+func removeEvent(ctx context.Context, service *calendar.Service, calendarID, eventID string) error {
+	err := service.Events.Delete(calendarID, eventID).Context(ctx).Do()
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// insertMayHaveSucceeded reports whether a failed insert could still have created the
+// event, i.e. the failure was not a definite rejection by the Calendar API.
+func insertMayHaveSucceeded(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern so search text matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// localEvents returns a page of the user's stored meetings matching the query, in
+// the same shape as the events read from Google Calendar, and the cursor of the next
+// page if there is one.
+func (h *Handler) localEvents(ctx context.Context, userID uuid.UUID, query *eventQuery) ([]map[string]interface{}, string, error) {
+	db := h.DB.WithContext(ctx).
+		Where("user_id = ? AND end_time > ? AND start_time < ?", userID, query.TimeMin, query.TimeMax)
+	if len(query.Calendars) > 0 {
+		db = db.Where("calendar_id IN ?", query.Calendars)
+	}
+	if query.Q != "" {
+		pattern := "%" + likeEscaper.Replace(query.Q) + "%"
+		db = db.Where("(title ILIKE ? OR description ILIKE ? OR attendees ILIKE ?)", pattern, pattern, pattern)
+	}
+
+	// Read one extra row to learn whether there is another page
+	var meetings []models.Meeting
+	err := db.Order("start_time, id").Offset(query.Offset).Limit(query.PageSize + 1).Find(&meetings).Error
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(meetings) > query.PageSize {
+		meetings = meetings[:query.PageSize]
+		next := *query
+		next.Offset += query.PageSize
+		nextCursor = encodeCursor(&next)
+	}
+
+	events := make([]map[string]interface{}, 0, len(meetings))
+	for _, m := range meetings {
+		attendees := []string{}
+		if m.Attendees != "" {
+			attendees = strings.Split(m.Attendees, ",")
+		}
+		events = append(events, map[string]interface{}{
+			"title":       m.Title,
+			"description": m.Description,
+			"start_time":  m.StartTime,
+			"end_time":    m.EndTime,
+			"event_id":    m.EventID,
+			"attendees":   attendees,
+			"created_by":  m.CreatedBy,
+			"account":     m.CreatedBy,
+			"calendar":    m.CalendarID,
+		})
+	}
+	return events, nextCursor, nil
+}
+
+// ownMeeting scopes a query to the meeting the signed-in user stored for the event
+// through the account and calendar it was found in. Other users who see the same
+// Google event, e.g. guests allowed to modify it, never touch that user's meeting.
+func (h *Handler) ownMeeting(ctx context.Context, ref *eventRef) *gorm.DB {
+	return h.DB.WithContext(ctx).
+		Where("user_id = ? AND identity_id = ? AND calendar_id = ? AND event_id = ?", ref.userID, ref.identity.ID, ref.calendarID, ref.event.Id)
+}
+
+// syncMeeting copies the event's details onto the signed-in user's meeting for it, if
+// there is one. A meeting already at the event's etag is left alone.
+func (h *Handler) syncMeeting(ctx context.Context, ref *eventRef) error {
+	event := ref.event
+	startTime, _ := time.Parse(time.RFC3339, event.Start.DateTime)
+	endTime, _ := time.Parse(time.RFC3339, event.End.DateTime)
+
+	attendees := make([]string, 0, len(event.Attendees))
+	for _, a := range event.Attendees {
+		attendees = append(attendees, a.Email)
+	}
+
+	return h.ownMeeting(ctx, ref).Model(&models.Meeting{}).
+		Where("(etag IS NULL OR etag <> ?)", event.Etag).
+		Updates(map[string]interface{}{
+			"etag":        event.Etag,
+			"title":       event.Summary,
+			"description": event.Description,
+			"start_time":  startTime,
+			"end_time":    endTime,
+			"attendees":   strings.Join(attendees, ","),
+		}).Error
+}
+
+// isNotFound reports whether a Calendar API error means the event does not exist.
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone)
+}
+
+// isPreconditionFailed reports whether a Calendar API error means the If-Match etag
+// no longer matched the event.
+func isPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
@@ -1,12 +1,14 @@
 package handler
 
 import (
-	"context"
 	"log"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
-	"github.com/coreos/go-oidc"
+	"google-calendar-api/models"
+	"google-calendar-api/utils"
 )
 
 // contextKey is a type-safe key for storing user information in the request context.
@@ -15,10 +17,15 @@ type contextKey string
 const userKey contextKey = "user"
 
 // AuthMiddleware validates authentication tokens from request headers or cookies.
-// It verifies the token against Google's OIDC provider and injects user details into the request context.
+// Bearer tokens may also be personal access tokens, which are checked against their
+// stored hash. It verifies the application-issued session token locally, without calling Google,
+// checks that its session has not been revoked, requires a CSRF token on state-changing
+// requests authenticated by the cookie, renews cookie sessions that are past half their
+// lifetime, loads the user and injects it into the request context as a *CurrentUser.
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var accessToken string
+		fromCookie := false
 
 		// Check "Authorization" header for a Bearer token
 		authHeader := r.Header.Get("Authorization")
@@ -31,54 +38,95 @@ func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 
 		// If no token found in header, check cookies
 		if accessToken == "" {
-			cookie, err := r.Cookie("token")
+			cookie, err := r.Cookie(sessionCookieName)
 			if err != nil {
 				http.Error(w, "Unauthorized: No valid authentication token", http.StatusUnauthorized)
 				return
 			}
 			accessToken = cookie.Value
+			fromCookie = true
 		}
 
-		// Validate token and set user info in request context
-		ctx, err := h.validateAndSetContext(r, accessToken)
-		if err != nil {
-			http.Error(w, "Unauthorized: Invalid authentication token", http.StatusUnauthorized)
+		// Personal access tokens are looked up in the database instead of being verified as JWTs
+		var claims *utils.SessionClaims
+		var pat *models.PersonalAccessToken
+		if !fromCookie && isPAT(accessToken) {
+			var err error
+			claims, pat, err = h.authenticatePAT(accessToken)
+			if err != nil {
+				log.Println("❌ Personal access token rejected:", err)
+				http.Error(w, "Unauthorized: Invalid authentication token", http.StatusUnauthorized)
+				return
+			}
+		} else {
+			var err error
+			claims, err = validateSessionToken(accessToken)
+			if err != nil {
+				http.Error(w, "Unauthorized: Invalid authentication token", http.StatusUnauthorized)
+				return
+			}
+
+			// Reject tokens whose session was logged out or revoked
+			userID, _ := claims.UserID()
+			if err := h.checkSession(r, claims, userID); err != nil {
+				log.Println("❌ Session rejected:", err)
+				http.Error(w, "Unauthorized: Session is no longer valid", http.StatusUnauthorized)
+				return
+			}
+
+			// Browsers attach the cookie to cross-site requests, so cookie sessions must
+			// prove the request came from our pages; Bearer tokens are never sent implicitly
+			if fromCookie && !validCSRF(r, claims) {
+				log.Printf("❌ CSRF check failed for %s %s", r.Method, r.URL.Path)
+				writeCSRFError(w)
+				return
+			}
+
+			// Sliding renewal: replace cookie sessions that are getting old
+			if fromCookie && needsRenewal(claims) {
+				now := time.Now()
+				if err := h.sessions.Touch(r.Context(), claims.SessionID, now, now.Add(h.sessionTTL)); err != nil {
+					log.Println("⚠️ Failed to extend session:", err)
+				} else if err := h.setSessionCookie(w, userID, claims.SessionID); err != nil {
+					log.Println("⚠️ Failed to renew session token:", err)
+				}
+			}
+		}
+
+		// Load the user once for every handler down the chain
+		var user models.User
+		if err := h.DB.Where("id = ?", claims.Subject).First(&user).Error; err != nil {
+			log.Println("❌ Failed to load authenticated user:", err)
+			http.Error(w, "Unauthorized: Unknown user", http.StatusUnauthorized)
 			return
 		}
 
-		// Proceed to the next handler with updated context
+		// Proceed to the next handler with the current user in the context
+		ctx := withCurrentUser(r.Context(), &CurrentUser{User: user, Claims: claims, AccessToken: pat})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// validateAndSetContext verifies the given token using Google's OIDC provider
-// and returns a new request context containing user details.
-func (h *Handler) validateAndSetContext(r *http.Request, idToken string) (context.Context, error) {
-	provider, err := oidc.NewProvider(r.Context(), "https://accounts.google.com")
+// validateSessionToken verifies an application-issued session token and returns its claims.
+func validateSessionToken(token string) (*utils.SessionClaims, error) {
+	claims, err := utils.ValidateToken(token)
 	if err != nil {
-		log.Println("❌ Failed to create OIDC provider:", err)
-		return r.Context(), err
+		log.Println("❌ Invalid session token:", err)
+		return nil, err
 	}
 
-	verifier := provider.Verifier(&oidc.Config{ClientID: h.oauthConfig.ClientID})
-	idTokenObj, err := verifier.Verify(r.Context(), idToken)
-	if err != nil {
-		log.Println("❌ Invalid ID Token:", err)
-		return r.Context(), err
+	if _, err := claims.UserID(); err != nil {
+		log.Println("❌ Invalid user ID in session token:", err)
+		return nil, err
 	}
+	return claims, nil
+}
 
-	// Extract user claims from ID token
-	var userInfo struct {
-		Sub   string `json:"sub"`
-		Email string `json:"email"`
-		Name  string `json:"name"`
-	}
-	if err := idTokenObj.Claims(&userInfo); err != nil {
-		log.Println("❌ Failed to parse ID Token claims:", err)
-		return r.Context(), err
+// clientIP returns the IP address of the client that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
-
-	// Store user info in request context
-	ctx := context.WithValue(r.Context(), userKey, userInfo)
-	return ctx, nil
+	return host
 }
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/models"
+	"google-calendar-api/utils"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Personal access tokens look like "pat_<prefix>_<secret>". The prefix is stored in
+// clear text to find the token; the whole token is only stored hashed.
+const (
+	patMarker    = "pat_"
+	patPrefixLen = 16 // Hex characters in the lookup prefix
+)
+
+// patScopes are the scopes a personal access token can be granted. They match the
+// permissions of the same name, so a token can never do more than its owner's role allows.
+var patScopes = map[string]Permission{
+	string(PermEventsRead):  PermEventsRead,
+	string(PermEventsWrite): PermEventsWrite,
+}
+
+// errInvalidPAT is returned for unknown, revoked, expired or malformed personal access tokens.
+var errInvalidPAT = errors.New("invalid personal access token")
+
+// isPAT reports whether a Bearer token is a personal access token rather than a session token.
+func isPAT(token string) bool {
+	return strings.HasPrefix(token, patMarker)
+}
+
+// hashPAT returns the hex SHA-256 of a personal access token.
+func hashPAT(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generatePAT returns a new personal access token and its lookup prefix.
+func generatePAT() (token, prefix string, err error) {
+	b := make([]byte, patPrefixLen/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(b)
+
+	secret, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return patMarker + prefix + "_" + secret, prefix, nil
+}
+
+// authenticatePAT looks up a personal access token by its prefix, checks its hash
+// and records its use. It returns the owner's user ID as session claims (with no
+// session ID) and the token itself.
+func (h *Handler) authenticatePAT(token string) (*utils.SessionClaims, *models.PersonalAccessToken, error) {
+	rest := strings.TrimPrefix(token, patMarker)
+	if len(rest) <= patPrefixLen || rest[patPrefixLen] != '_' {
+		return nil, nil, errInvalidPAT
+	}
+	prefix := rest[:patPrefixLen]
+
+	var pat models.PersonalAccessToken
+	if err := h.DB.Where("prefix = ?", prefix).First(&pat).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errInvalidPAT
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(hashPAT(token)), []byte(pat.Hash)) != 1 || !pat.Active(now) {
+		return nil, nil, errInvalidPAT
+	}
+
+	// Avoid a write on every request from busy scripts
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) >= lastSeenInterval {
+		if err := h.DB.Model(&pat).Update("last_used_at", now).Error; err != nil {
+			log.Println("⚠️ Failed to record token use:", err)
+		}
+	}
+
+	claims := &utils.SessionClaims{StandardClaims: jwt.StandardClaims{Subject: pat.UserID.String()}}
+	return claims, &pat, nil
+}
+
+// patView is the JSON representation of a personal access token.
+type patView struct {
+	models.PersonalAccessToken
+	Scopes []string `json:"scopes"`
+	Token  string   `json:"token,omitempty"` // Only returned when the token is created
+}
+
+// CreatePersonalAccessToken creates a personal access token for the current user.
+// The token is returned once in the response and cannot be retrieved again.
+func (h *Handler) CreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	var request struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"` // 0 for a token that does not expire
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(request.Name) == "" || len(request.Scopes) == 0 || request.ExpiresInDays < 0 {
+		writeJSONError(w, http.StatusBadRequest, apiError{
+			Error:   "invalid_request",
+			Message: "A name and at least one scope are required.",
+		})
+		return
+	}
+	for _, scope := range request.Scopes {
+		if _, ok := patScopes[scope]; !ok {
+			writeJSONError(w, http.StatusBadRequest, apiError{
+				Error:   "invalid_scope",
+				Message: "Unknown scope " + scope + ". Valid scopes are events:read and events:write.",
+			})
+			return
+		}
+	}
+
+	token, prefix, err := generatePAT()
+	if err != nil {
+		log.Println("❌ Failed to generate personal access token:", err)
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	pat := models.PersonalAccessToken{
+		ID:     uuid.New(),
+		UserID: userID,
+		Name:   strings.TrimSpace(request.Name),
+		Prefix: prefix,
+		Hash:   hashPAT(token),
+		Scopes: strings.Join(request.Scopes, " "),
+	}
+	if request.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, request.ExpiresInDays)
+		pat.ExpiresAt = &expiresAt
+	}
+	if err := h.DB.Create(&pat).Error; err != nil {
+		log.Println("❌ Failed to save personal access token:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(r, audit.Entry{Action: audit.ActionTokenCreate, Target: pat.ID.String(), Outcome: audit.Success, Detail: pat.Name + " (" + pat.Scopes + ")"})
+	log.Printf("✅ Personal access token %s created for user %s", pat.Prefix, userID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(patView{PersonalAccessToken: pat, Scopes: pat.ScopeList(), Token: token})
+}
+
+// ListPersonalAccessTokens returns the current user's personal access tokens, without their secrets.
+func (h *Handler) ListPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	var pats []models.PersonalAccessToken
+	if err := h.DB.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at").Find(&pats).Error; err != nil {
+		log.Println("❌ Failed to list personal access tokens:", err)
+		http.Error(w, "Failed to list tokens", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]patView, 0, len(pats))
+	for _, pat := range pats {
+		views = append(views, patView{PersonalAccessToken: pat, Scopes: pat.ScopeList()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tokens": views})
+}
+
+// RevokePersonalAccessToken revokes one of the current user's personal access tokens.
+func (h *Handler) RevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	result := h.DB.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		log.Println("❌ Failed to revoke personal access token:", result.Error)
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	h.recordAudit(r, audit.Entry{Action: audit.ActionTokenRevoke, Target: id.String(), Outcome: audit.Success})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"google-calendar-api/models"
+)
+
+func TestGeneratePAT(t *testing.T) {
+	format := regexp.MustCompile(`^pat_[0-9a-f]{16}_[A-Za-z0-9_-]{43}$`)
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		token, prefix, err := generatePAT()
+		if err != nil {
+			t.Fatalf("generatePAT: %v", err)
+		}
+		if !format.MatchString(token) {
+			t.Fatalf("token %q does not match %s", token, format)
+		}
+		if len(prefix) != patPrefixLen || !strings.HasPrefix(token, patMarker+prefix+"_") {
+			t.Fatalf("prefix %q is not the token's lookup prefix", prefix)
+		}
+		if !isPAT(token) {
+			t.Fatalf("isPAT(%q) = false", token)
+		}
+		if seen[prefix] {
+			t.Fatalf("prefix %q was issued twice", prefix)
+		}
+		seen[prefix] = true
+	}
+}
+
+func TestIsPAT(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{token: "pat_0123456789abcdef_secret", want: true},
+		{token: "eyJhbGciOiJIUzI1NiJ9.e30.sig", want: false},
+		{token: "PAT_0123456789abcdef_secret", want: false},
+		{token: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isPAT(tt.token); got != tt.want {
+			t.Errorf("isPAT(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestHashPAT(t *testing.T) {
+	token, _, err := generatePAT()
+	if err != nil {
+		t.Fatalf("generatePAT: %v", err)
+	}
+
+	hash := hashPAT(token)
+	if len(hash) != 64 || strings.Contains(hash, token) {
+		t.Errorf("hashPAT(%q) = %q, want a hex SHA-256", token, hash)
+	}
+	if hashPAT(token) != hash {
+		t.Error("hashPAT is not deterministic")
+	}
+	if hashPAT(token+"x") == hash {
+		t.Error("different tokens share a hash")
+	}
+}
+
+func TestAuthenticatePATRejectsMalformed(t *testing.T) {
+	// Malformed tokens must be rejected before the database is consulted
+	h := &Handler{}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "marker only", token: "pat_"},
+		{name: "prefix without secret", token: "pat_0123456789abcdef"},
+		{name: "short prefix", token: "pat_01234567_secret"},
+		{name: "long prefix", token: "pat_0123456789abcdef01_secret"},
+		{name: "missing separator", token: "pat_0123456789abcdefsecret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := h.authenticatePAT(tt.token); !errors.Is(err, errInvalidPAT) {
+				t.Errorf("authenticatePAT(%q) error = %v, want errInvalidPAT", tt.token, err)
+			}
+		})
+	}
+}
+
+func TestPersonalAccessTokenActive(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		token models.PersonalAccessToken
+		want  bool
+	}{
+		{name: "no expiry", token: models.PersonalAccessToken{}, want: true},
+		{name: "not yet expired", token: models.PersonalAccessToken{ExpiresAt: &future}, want: true},
+		{name: "expired", token: models.PersonalAccessToken{ExpiresAt: &past}, want: false},
+		{name: "expires now", token: models.PersonalAccessToken{ExpiresAt: &now}, want: false},
+		{name: "revoked", token: models.PersonalAccessToken{RevokedAt: &past, ExpiresAt: &future}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Active(now); got != tt.want {
+				t.Errorf("Active = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
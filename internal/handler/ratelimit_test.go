@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google-calendar-api/internal/ratelimit"
+)
+
+// failingLimiter is a Limiter whose store is unavailable.
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store unavailable")
+}
+
+func TestRateLimit(t *testing.T) {
+	h := &Handler{
+		limiter:    ratelimit.NewMemoryLimiter(),
+		rateLimits: map[string]ratelimit.Limit{"auth": {Requests: 2, Per: time.Minute}},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	limited := h.RateLimit("auth")(next)
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		wantStatus     int
+		wantRemaining  string
+		wantRetryAfter string
+	}{
+		{name: "first request", remoteAddr: "192.0.2.1:1000", wantStatus: http.StatusNoContent, wantRemaining: "1"},
+		{name: "second request from another port", remoteAddr: "192.0.2.1:2000", wantStatus: http.StatusNoContent, wantRemaining: "0"},
+		{name: "over the limit", remoteAddr: "192.0.2.1:1000", wantStatus: http.StatusTooManyRequests, wantRemaining: "0", wantRetryAfter: "30"},
+		{name: "another client", remoteAddr: "192.0.2.2:1000", wantStatus: http.StatusNoContent, wantRemaining: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/auth/google/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+			limited.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+				t.Errorf("RateLimit-Limit = %q, want 2", got)
+			}
+			if got := w.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("RateLimit-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			if tt.wantStatus == http.StatusTooManyRequests {
+				var body apiError
+				if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Error != "rate_limited" {
+					t.Errorf("body = %+v, %v; want rate_limited", body, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitAllowsWhenLimiterFails(t *testing.T) {
+	h := &Handler{
+		limiter:    failingLimiter{},
+		rateLimits: map[string]ratelimit.Limit{"api": {Requests: 1, Per: time.Minute}},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	w := httptest.NewRecorder()
+	h.RateLimit("api")(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/me", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want the request let through", w.Code)
+	}
+}
+
+func TestCeilSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 0, want: "0"},
+		{d: time.Millisecond, want: "1"},
+		{d: time.Second, want: "1"},
+		{d: 1500 * time.Millisecond, want: "2"},
+		{d: time.Minute, want: "60"},
+	}
+
+	for _, tt := range tests {
+		if got := ceilSeconds(tt.d); got != tt.want {
+			t.Errorf("ceilSeconds(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"google-calendar-api/internal/provider"
+	"google-calendar-api/models"
+	"google-calendar-api/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Permission is an action a role may perform on the API.
+type Permission string
+
+// API permissions.
+const (
+	PermProfile     Permission = "profile"      // Read one's own profile
+	PermAccount     Permission = "account"      // View the dashboard and manage one's own sessions and linked accounts
+	PermEventsRead  Permission = "events:read"  // List calendar events
+	PermEventsWrite Permission = "events:write" // Create and modify calendar events
+	PermUsersManage Permission = "users:manage" // List users and change their roles
+	PermAuditRead   Permission = "audit:read"   // Query and export the audit log
+)
+
+// rolePermissions lists what each role may do.
+var rolePermissions = map[string][]Permission{
+	models.RoleAdmin:  {PermProfile, PermAccount, PermEventsRead, PermEventsWrite, PermUsersManage, PermAuditRead},
+	models.RoleMember: {PermProfile, PermAccount, PermEventsRead, PermEventsWrite},
+	models.RoleViewer: {PermProfile, PermAccount, PermEventsRead},
+}
+
+// patImplicitPermissions are granted to every personal access token regardless of its scopes.
+var patImplicitPermissions = map[Permission]bool{
+	PermProfile: true,
+}
+
+// roleAllows reports whether the role grants the permission.
+func roleAllows(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermissions returns a middleware for a subrouter that looks up the permission
+// of the matched route by its name and rejects users whose role does not grant it.
+// Routes without an entry are denied, so every route on the subrouter must be named.
+// Requests authenticated with a personal access token also need the permission among
+// the token's scopes. It must run after AuthMiddleware, which loads the user's role
+// from the database on each request so that role changes apply immediately.
+func (h *Handler) RequirePermissions(routePermissions map[string]Permission) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var name string
+			if route := mux.CurrentRoute(r); route != nil {
+				name = route.GetName()
+			}
+			perm, ok := routePermissions[name]
+			if !ok {
+				log.Printf("❌ No permission configured for route %q (%s)", name, r.URL.Path)
+				writeJSONError(w, http.StatusForbidden, apiError{
+					Error:   "forbidden",
+					Message: "This endpoint is not available.",
+				})
+				return
+			}
+
+			current, ok := requireUser(w, r)
+			if !ok {
+				return
+			}
+			user := current.User
+
+			if !roleAllows(user.Role, perm) {
+				log.Printf("❌ Role %s lacks permission %s for %s", user.Role, perm, r.URL.Path)
+				writeJSONError(w, http.StatusForbidden, apiError{
+					Error:      "insufficient_role",
+					Message:    "Your role does not allow this action.",
+					Role:       user.Role,
+					Permission: string(perm),
+				})
+				return
+			}
+
+			// Personal access tokens are further limited to the scopes they were granted
+			if pat := current.AccessToken; pat != nil && !patImplicitPermissions[perm] && !pat.HasScope(string(perm)) {
+				log.Printf("❌ Personal access token %s lacks scope %s for %s", pat.Prefix, perm, r.URL.Path)
+				writeJSONError(w, http.StatusForbidden, apiError{
+					Error:      "insufficient_token_scope",
+					Message:    "This access token was not granted the scope this endpoint requires.",
+					Permission: string(perm),
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// roleConfig decides the role of users when they sign in.
+type roleConfig struct {
+	defaultRole string              // Role given to new users
+	adminEmails map[string]struct{} // Users promoted to admin when they sign in
+}
+
+// roleConfigFromEnv reads DEFAULT_USER_ROLE (default "member") and ADMIN_EMAILS,
+// a comma-separated list of addresses bootstrapped as administrators.
+func roleConfigFromEnv() roleConfig {
+	config := roleConfig{
+		defaultRole: utils.GetEnv("DEFAULT_USER_ROLE", models.RoleMember),
+		adminEmails: make(map[string]struct{}),
+	}
+	if !models.ValidRole(config.defaultRole) {
+		log.Printf("⚠️ Invalid DEFAULT_USER_ROLE %q, using %s", config.defaultRole, models.RoleMember)
+		config.defaultRole = models.RoleMember
+	}
+	for _, email := range strings.Split(utils.GetEnv("ADMIN_EMAILS", ""), ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			config.adminEmails[email] = struct{}{}
+		}
+	}
+	return config
+}
+
+// isAdminEmail reports whether the address is bootstrapped as an administrator.
+func (c roleConfig) isAdminEmail(email string) bool {
+	_, ok := c.adminEmails[strings.ToLower(email)]
+	return ok
+}
+
+// roleFor returns the role for a new user with the given profile. Only addresses the
+// provider has verified are bootstrapped as administrators.
+func (c roleConfig) roleFor(profile provider.Profile) string {
+	if profile.EmailVerified && c.isAdminEmail(profile.Email) {
+		return models.RoleAdmin
+	}
+	return c.defaultRole
+}
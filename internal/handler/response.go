@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"google-calendar-api/internal/tokens"
+)
+
+// apiError is the JSON body of machine-readable API error responses.
+type apiError struct {
+	Error     string `json:"error"`                // Stable machine-readable error code
+	Message   string `json:"message"`              // Human-readable description
+	ReauthURL string `json:"reauth_url,omitempty"` // Where to send the user to grant access again
+
+	AuthorizeURL string `json:"authorize_url,omitempty"` // Where to send the user to grant a missing scope
+	Role         string `json:"role,omitempty"`          // Caller's role, for insufficient_role
+	Permission   string `json:"permission,omitempty"`    // Permission the endpoint requires, for insufficient_role
+
+	Current interface{} `json:"current,omitempty"` // Current representation of the resource, for precondition_failed
+
+	FailedAccounts []identityView `json:"failed_accounts,omitempty"` // Linked accounts an operation failed for, for revocation_failed
+}
+
+// writeJSONError writes an apiError with the given status code.
+func writeJSONError(w http.ResponseWriter, status int, body apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeTokenError reports a failure to obtain or use the user's Google token.
+// Grants that need to be re-consented get a structured reauthorization_required
+// response, missing Calendar scopes get insufficient_scope, and anything else
+// gets the given status and message.
+func writeTokenError(w http.ResponseWriter, err error, status int, message string) {
+	var scopeErr *insufficientScopeError
+	if errors.As(err, &scopeErr) {
+		writeJSONError(w, http.StatusForbidden, apiError{
+			Error:        "insufficient_scope",
+			Message:      "Google Calendar access has not been granted yet.",
+			AuthorizeURL: scopeErr.authorizeURL(),
+		})
+		return
+	}
+	if errors.Is(err, errGoogleAccountRequired) {
+		writeJSONError(w, http.StatusForbidden, apiError{
+			Error:   "google_account_required",
+			Message: "Calendar features are only available to users signed in with Google.",
+		})
+		return
+	}
+	var reauthErr *tokens.ReauthRequiredError
+	if errors.As(err, &reauthErr) {
+		writeJSONError(w, http.StatusUnauthorized, apiError{
+			Error:     "reauthorization_required",
+			Message:   "Google access has expired or was revoked. Please grant access again.",
+			ReauthURL: reauthURL(reauthErr),
+		})
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// renderError renders the error page with the given status code, title and message.
+func renderError(w http.ResponseWriter, status int, title, message string) {
+	tmplPath := filepath.Join("templates", "error.html")
+	tmpl, err := template.ParseFiles(tmplPath)
+	if err != nil {
+		log.Println("❌ Failed to load error template:", err)
+		http.Error(w, message, status)
+		return
+	}
+
+	data := struct {
+		Title   string
+		Message string
+	}{
+		Title:   title,
+		Message: message,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Println("❌ Failed to render error template:", err)
+	}
+}
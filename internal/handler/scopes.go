@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"google-calendar-api/internal/tokens"
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// Google Calendar OAuth scopes.
+const (
+	scopeCalendar               = "https://www.googleapis.com/auth/calendar"                 // Full access to Google Calendar
+	scopeCalendarReadonly       = "https://www.googleapis.com/auth/calendar.readonly"        // Read-only access to calendars
+	scopeCalendarEvents         = "https://www.googleapis.com/auth/calendar.events"          // Manage calendar events
+	scopeCalendarEventsReadonly = "https://www.googleapis.com/auth/calendar.events.readonly" // Read calendar events
+)
+
+// calendarAccess is the level of Calendar API access an operation needs.
+type calendarAccess string
+
+const (
+	accessRead      calendarAccess = "read"      // Listing events
+	accessWrite     calendarAccess = "write"     // Creating or modifying events
+	accessCalendars calendarAccess = "calendars" // Listing calendars, which the event scopes do not allow
+)
+
+// satisfiedBy lists the scopes that grant each access level.
+var satisfiedBy = map[calendarAccess][]string{
+	accessRead:      {scopeCalendarReadonly, scopeCalendarEventsReadonly, scopeCalendarEvents, scopeCalendar},
+	accessWrite:     {scopeCalendarEvents, scopeCalendar},
+	accessCalendars: {scopeCalendarReadonly, scopeCalendar},
+}
+
+// requestedScope is the scope asked for when the user lacks an access level.
+var requestedScope = map[calendarAccess]string{
+	accessRead:      scopeCalendarReadonly,
+	accessWrite:     scopeCalendarEvents,
+	accessCalendars: scopeCalendarReadonly,
+}
+
+// parseCalendarAccess validates an access level from a query parameter.
+func parseCalendarAccess(value string) (calendarAccess, bool) {
+	access := calendarAccess(value)
+	_, ok := requestedScope[access]
+	return access, ok
+}
+
+// splitScopes returns the space-separated scopes as a list, never nil.
+func splitScopes(scopes string) []string {
+	return append([]string{}, strings.Fields(scopes)...)
+}
+
+// hasCalendarAccess reports whether the space-separated granted scopes cover the access level.
+func hasCalendarAccess(grantedScopes string, access calendarAccess) bool {
+	granted := strings.Fields(grantedScopes)
+	for _, want := range satisfiedBy[access] {
+		for _, have := range granted {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// googleProviderName is the provider whose tokens are used for Google Calendar.
+const googleProviderName = "google"
+
+// errGoogleAccountRequired is returned when a user with no linked Google account uses a Calendar feature.
+var errGoogleAccountRequired = errors.New("a Google account is required for calendar features")
+
+// insufficientScopeError reports that the user has not granted the Calendar scope an operation needs.
+type insufficientScopeError struct {
+	access     calendarAccess
+	identityID uuid.UUID // Linked Google identity to ask for the scope
+}
+
+func (e *insufficientScopeError) Error() string {
+	return fmt.Sprintf("calendar %s access has not been granted", e.access)
+}
+
+// authorizeURL is where the user grants the missing access level.
+func (e *insufficientScopeError) authorizeURL() string {
+	query := url.Values{"access": {string(e.access)}}
+	if e.identityID != uuid.Nil {
+		query.Set("identity", e.identityID.String())
+	}
+	return "/auth/google/authorize?" + query.Encode()
+}
+
+// reauthURL is where the user consents again for an identity whose grant is no longer
+// usable. Google identities are sent through GoogleAuthorize for the Calendar access
+// the grant held, with the consent prompt forced so Google issues a new refresh token;
+// other identities, and Google identities without Calendar access, are linked again.
+// Either way the identity's account is passed to the provider as a login hint.
+func reauthURL(e *tokens.ReauthRequiredError) string {
+	query := url.Values{"identity": {e.IdentityID.String()}}
+	if e.Provider == googleProviderName {
+		for _, access := range grantedAccess(e.GrantedScopes) {
+			query.Add("access", string(access))
+		}
+		if len(query["access"]) > 0 {
+			query.Set("prompt", "consent")
+			return "/auth/google/authorize?" + query.Encode()
+		}
+	}
+	return "/auth/" + url.PathEscape(e.Provider) + "/link?" + query.Encode()
+}
+
+// grantedAccess returns the access levels whose requested scopes restore the
+// Calendar access held by the space-separated granted scopes.
+func grantedAccess(grantedScopes string) []calendarAccess {
+	var levels []calendarAccess
+	for _, access := range []calendarAccess{accessWrite, accessCalendars} {
+		if hasCalendarAccess(grantedScopes, access) {
+			levels = append(levels, access)
+		}
+	}
+	if len(levels) == 0 && hasCalendarAccess(grantedScopes, accessRead) {
+		levels = append(levels, accessRead)
+	}
+	return levels
+}
+
+// GoogleAuthorize asks an already signed-in user for additional Calendar scopes, one
+// for each access parameter. Previously granted scopes are kept through
+// include_granted_scopes. The optional identity parameter names the linked Google
+// identity to authorize and is passed to Google as a login hint; the callback only
+// accepts one of the user's own identities. Passing prompt=consent forces the consent
+// screen so Google issues a new refresh token, which reauthorization needs.
+func (h *Handler) GoogleAuthorize(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireSessionUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	values := r.URL.Query()["access"]
+	if len(values) == 0 {
+		http.Error(w, "Invalid access level", http.StatusBadRequest)
+		return
+	}
+
+	google, ok := h.providers.Get(googleProviderName)
+	if !ok {
+		http.Error(w, "Google sign-in is not configured", http.StatusNotFound)
+		return
+	}
+
+	config := *google.OAuth
+	config.Scopes = append([]string{}, google.OAuth.Scopes...)
+	for _, value := range values {
+		access, ok := parseCalendarAccess(value)
+		if !ok {
+			http.Error(w, "Invalid access level", http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(config.Scopes, requestedScope[access]) {
+			config.Scopes = append(config.Scopes, requestedScope[access])
+		}
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if hint, ok := h.loginHint(userID, googleProviderName, r.URL.Query().Get("identity")); ok {
+		opts = append(opts, hint)
+	}
+	if r.URL.Query().Get("prompt") == "consent" {
+		opts = append(opts, oauth2.SetAuthURLParam("prompt", "consent"))
+	}
+
+	attempt := authAttempt{purpose: purposeAuthorize, userID: userID, returnTo: "/api/dashboard"}
+	h.startAuthorization(w, r, google, &config, attempt, opts...)
+}
+
+// loginHint returns the login_hint parameter for the user's identity at the provider
+// named by identityParam, if there is one with a known email address.
+func (h *Handler) loginHint(userID uuid.UUID, providerName, identityParam string) (oauth2.AuthCodeOption, bool) {
+	id, err := uuid.Parse(identityParam)
+	if err != nil {
+		return nil, false
+	}
+	var identity models.Identity
+	if err := h.DB.Where("id = ? AND user_id = ? AND provider = ?", id, userID, providerName).First(&identity).Error; err != nil || identity.Email == "" {
+		return nil, false
+	}
+	return oauth2.SetAuthURLParam("login_hint", identity.Email), true
+}
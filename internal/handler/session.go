@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/models"
+	"google-calendar-api/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// sessionCookieName is the cookie holding the application-issued session token.
+const sessionCookieName = "token"
+
+// defaultSessionTTL is the session lifetime used unless SESSION_TTL overrides it.
+const defaultSessionTTL = 24 * time.Hour
+
+// lastSeenInterval limits how often a session's last-seen timestamp is written.
+const lastSeenInterval = time.Minute
+
+// issueSession records a new session for the user and stores its token in the session cookie.
+func (h *Handler) issueSession(w http.ResponseWriter, r *http.Request, userID uuid.UUID) error {
+	s, err := h.createSession(r, userID)
+	if err != nil {
+		return err
+	}
+	return h.setSessionCookie(w, userID, s.ID)
+}
+
+// createSession records a new session for the user, attributed to the requesting client.
+func (h *Handler) createSession(r *http.Request, userID uuid.UUID) (*models.Session, error) {
+	now := time.Now()
+	s := &models.Session{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		IP:         clientIP(r),
+		UserAgent:  r.UserAgent(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(h.sessionTTL),
+	}
+	if err := h.sessions.Create(r.Context(), s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// setSessionCookie signs a fresh token for the given session and stores it in the session cookie.
+func (h *Handler) setSessionCookie(w http.ResponseWriter, userID uuid.UUID, sessionID string) error {
+	token, err := utils.GenerateToken(userID, sessionID, h.sessionTTL)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(h.sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// clearSessionCookie expires the session cookie in the browser.
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+// cookieSession returns the claims and user of a valid session cookie on routes that
+// do not require authentication. It reports false if the request has no usable session.
+func (h *Handler) cookieSession(r *http.Request) (*utils.SessionClaims, uuid.UUID, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, uuid.Nil, false
+	}
+	claims, err := validateSessionToken(cookie.Value)
+	if err != nil {
+		return nil, uuid.Nil, false
+	}
+	userID, _ := claims.UserID()
+	if err := h.checkSession(r, claims, userID); err != nil {
+		return nil, uuid.Nil, false
+	}
+	return claims, userID, true
+}
+
+// needsRenewal reports whether a session token has used up more than half of its
+// lifetime and should be replaced, giving active users a sliding expiry.
+func needsRenewal(claims *utils.SessionClaims) bool {
+	issued := time.Unix(claims.IssuedAt, 0)
+	expires := time.Unix(claims.ExpiresAt, 0)
+	return time.Now().After(issued.Add(expires.Sub(issued) / 2))
+}
+
+// checkSession ensures the session named in the token claims still exists, belongs
+// to the token's user and has not been revoked or expired. It also records activity.
+func (h *Handler) checkSession(r *http.Request, claims *utils.SessionClaims, userID uuid.UUID) error {
+	s, err := h.sessions.Get(r.Context(), claims.SessionID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if s.UserID != userID || !s.Active(now) {
+		return errors.New("session is revoked or expired")
+	}
+
+	if now.Sub(s.LastSeenAt) > lastSeenInterval {
+		if err := h.sessions.Touch(r.Context(), s.ID, now, s.ExpiresAt); err != nil {
+			log.Println("⚠️ Failed to update session activity:", err)
+		}
+	}
+	return nil
+}
+
+// ListSessions returns the current user's active sessions.
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	sessions, err := h.sessions.ListByUser(r.Context(), userID)
+	if err != nil {
+		log.Println("❌ Failed to list sessions:", err)
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	type sessionView struct {
+		models.Session
+		Current bool `json:"current"` // Whether this is the session making the request
+	}
+	views := make([]sessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, sessionView{Session: s, Current: s.ID == current.SessionID()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": views,
+	})
+}
+
+// RevokeSession revokes one of the current user's sessions.
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	id := mux.Vars(r)["id"]
+	s, err := h.sessions.Get(r.Context(), id)
+	if err != nil || s.UserID != userID {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.sessions.Revoke(r.Context(), id); err != nil {
+		log.Println("❌ Failed to revoke session:", err)
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(r, audit.Entry{Action: audit.ActionSessionRevoke, Target: id, Outcome: audit.Success})
+
+	if id == current.SessionID() {
+		clearSessionCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllSessions logs the current user out everywhere, including this session.
+func (h *Handler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	current, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	userID := current.ID()
+
+	if err := h.sessions.RevokeAll(r.Context(), userID); err != nil {
+		log.Println("❌ Failed to revoke sessions:", err)
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, audit.Entry{Action: audit.ActionSessionRevoke, Target: "all", Outcome: audit.Success})
+
+	clearSessionCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// stateCookieName is the cookie that binds a pending OAuth state to the browser that started the login.
+const stateCookieName = "oauthstate"
+
+// stateTTL bounds how long a user has to complete the consent screen.
+const stateTTL = 10 * time.Minute
+
+// authPurpose says what the callback should do with a completed authorization.
+type authPurpose string
+
+const (
+	purposeLogin     authPurpose = ""          // Sign in, creating the user on first use
+	purposeAuthorize authPurpose = "authorize" // Grant extra scopes to an already linked identity
+	purposeLink      authPurpose = "link"      // Link another provider account to the signed-in user
+)
+
+// authAttempt records a pending OAuth authorization started by Login, LinkIdentity or GoogleAuthorize.
+type authAttempt struct {
+	codeVerifier string      // PKCE code_verifier sent with the token exchange
+	provider     string      // Name of the provider the authorization was sent to
+	purpose      authPurpose // What the callback completes
+	userID       uuid.UUID   // Signed-in user linking or authorizing; uuid.Nil for a login
+	returnTo     string      // Where to send the user after linking or authorizing
+	expiresAt    time.Time   // Moment after which the state is no longer accepted
+}
+
+// stateStore keeps pending OAuth states server-side so that each one can be
+// verified and consumed exactly once.
+type stateStore struct {
+	mu       sync.Mutex
+	attempts map[string]authAttempt
+	ttl      time.Duration
+}
+
+// newStateStore creates an empty store whose states expire after ttl.
+func newStateStore(ttl time.Duration) *stateStore {
+	return &stateStore{
+		attempts: make(map[string]authAttempt),
+		ttl:      ttl,
+	}
+}
+
+// Create generates a new cryptographically random state and registers the attempt.
+func (s *stateStore) Create(attempt authAttempt) (string, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	attempt.expiresAt = time.Now().Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.attempts[state] = attempt
+	return state, nil
+}
+
+// Consume removes the state from the store and returns its attempt.
+// It reports false if the state is unknown, already used or expired.
+func (s *stateStore) Consume(state string) (authAttempt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[state]
+	if !ok {
+		return authAttempt{}, false
+	}
+	delete(s.attempts, state)
+
+	if time.Now().After(attempt.expiresAt) {
+		return authAttempt{}, false
+	}
+	return attempt, true
+}
+
+// prune drops expired attempts. The caller must hold s.mu.
+func (s *stateStore) prune() {
+	now := time.Now()
+	for state, attempt := range s.attempts {
+		if now.After(attempt.expiresAt) {
+			delete(s.attempts, state)
+		}
+	}
+}
+
+// randomString returns n random bytes encoded as unpadded base64url.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestStateStoreConsume(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		state  func(s *stateStore, created string) string
+		wantOK bool
+	}{
+		{
+			name:   "fresh state",
+			ttl:    time.Minute,
+			state:  func(s *stateStore, created string) string { return created },
+			wantOK: true,
+		},
+		{
+			name: "replayed state",
+			ttl:  time.Minute,
+			state: func(s *stateStore, created string) string {
+				s.Consume(created)
+				return created
+			},
+		},
+		{
+			name:  "expired state",
+			ttl:   -time.Second,
+			state: func(s *stateStore, created string) string { return created },
+		},
+		{
+			name:  "unknown state",
+			ttl:   time.Minute,
+			state: func(s *stateStore, created string) string { return created + "x" },
+		},
+		{
+			name:  "empty state",
+			ttl:   time.Minute,
+			state: func(s *stateStore, created string) string { return "" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStateStore(tt.ttl)
+			created, err := s.Create(authAttempt{provider: "google", purpose: purposeLink, userID: userID})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			attempt, ok := s.Consume(tt.state(s, created))
+			if ok != tt.wantOK {
+				t.Fatalf("Consume ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (attempt.provider != "google" || attempt.purpose != purposeLink || attempt.userID != userID) {
+				t.Errorf("Consume returned %+v, want the created attempt", attempt)
+			}
+		})
+	}
+}
+
+func TestStateStoreCreateIsUnique(t *testing.T) {
+	s := newStateStore(time.Minute)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		state, err := s.Create(authAttempt{})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if len(state) < 43 {
+			t.Fatalf("state %q is shorter than 256 bits", state)
+		}
+		if seen[state] {
+			t.Fatalf("state %q was issued twice", state)
+		}
+		seen[state] = true
+	}
+}
+
+func TestStateStorePrunesExpired(t *testing.T) {
+	s := newStateStore(-time.Second)
+	for i := 0; i < 3; i++ {
+		if _, err := s.Create(authAttempt{}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if len(s.attempts) != 1 {
+		t.Errorf("store holds %d attempts, want only the latest", len(s.attempts))
+	}
+}
+
+func TestVerifyState(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   func(state string) string
+		cookie  func(state string) string
+		replay  bool
+		wantErr bool
+	}{
+		{
+			name:   "matching state and cookie",
+			query:  func(state string) string { return state },
+			cookie: func(state string) string { return state },
+		},
+		{
+			name:    "missing state parameter",
+			query:   func(state string) string { return "" },
+			cookie:  func(state string) string { return state },
+			wantErr: true,
+		},
+		{
+			name:    "missing cookie",
+			query:   func(state string) string { return state },
+			cookie:  func(state string) string { return "" },
+			wantErr: true,
+		},
+		{
+			name:    "cookie from another attempt",
+			query:   func(state string) string { return state },
+			cookie:  func(state string) string { return state[1:] + "A" },
+			wantErr: true,
+		},
+		{
+			name:    "replayed callback",
+			query:   func(state string) string { return state },
+			cookie:  func(state string) string { return state },
+			replay:  true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{states: newStateStore(time.Minute)}
+			state, err := h.states.Create(authAttempt{provider: "google"})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			newRequest := func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/auth/google/callback?state="+tt.query(state), nil)
+				if value := tt.cookie(state); value != "" {
+					r.AddCookie(&http.Cookie{Name: stateCookieName, Value: value})
+				}
+				return r
+			}
+			if tt.replay {
+				if _, err := h.verifyState(httptest.NewRecorder(), newRequest()); err != nil {
+					t.Fatalf("first verifyState: %v", err)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			attempt, err := h.verifyState(w, newRequest())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyState error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && attempt.provider != "google" {
+				t.Errorf("verifyState returned provider %q, want google", attempt.provider)
+			}
+
+			cleared := false
+			for _, c := range w.Result().Cookies() {
+				if c.Name == stateCookieName && c.MaxAge < 0 {
+					cleared = true
+				}
+			}
+			if !cleared {
+				t.Error("verifyState did not clear the state cookie")
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Package jwks provides a cached JSON Web Key Set used to verify ID tokens.
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pquerna/cachecontrol"
+	jose "gopkg.in/go-jose/go-jose.v2"
+)
+
+// defaultTTL is used when the JWKS response carries no usable caching headers.
+const defaultTTL = time.Hour
+
+// minRefreshInterval limits how often the keys are refetched, so tokens with random
+// "kid" headers cannot be used to hammer the provider, and an outage of its JWKS
+// endpoint does not turn every verification into a blocking fetch.
+const minRefreshInterval = time.Minute
+
+// KeySet fetches and caches the signing keys published at a JWKS URL.
+// It refreshes the keys when the cache expires and when a token is signed with
+// a key ID it has not seen, which covers provider key rotation.
+// It implements oidc.KeySet.
+type KeySet struct {
+	url    string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        []jose.JSONWebKey
+	expiry      time.Time
+	lastFetched time.Time
+}
+
+// NewKeySet creates a KeySet for the given JWKS URL. If client is nil, http.DefaultClient is used.
+func NewKeySet(url string, client *http.Client) *KeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &KeySet{url: url, client: client}
+}
+
+// VerifySignature verifies the JWS signature of a raw JWT and returns its payload.
+func (k *KeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: malformed jwt: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("jwks: expected exactly one signature")
+	}
+	keyID := jws.Signatures[0].Header.KeyID
+
+	keys, err := k.keysFor(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if keyID == "" || key.KeyID == keyID {
+			if payload, err := jws.Verify(&key); err == nil {
+				return payload, nil
+			}
+		}
+	}
+	return nil, errors.New("jwks: failed to verify token signature")
+}
+
+// keysFor returns the cached keys, refreshing them first if they have expired
+// or if keyID is not among them, at most once per minRefreshInterval.
+func (k *KeySet) keysFor(ctx context.Context, keyID string) ([]jose.JSONWebKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	expired := now.After(k.expiry)
+	unknown := keyID != "" && !containsKey(k.keys, keyID)
+
+	if (expired || unknown) && now.Sub(k.lastFetched) >= minRefreshInterval {
+		if err := k.refresh(ctx); err != nil {
+			// Keep serving the cached keys if the provider is briefly unavailable
+			if len(k.keys) == 0 {
+				return nil, err
+			}
+		}
+	}
+	if len(k.keys) == 0 {
+		return nil, errors.New("jwks: no keys available, last fetch failed")
+	}
+	return k.keys, nil
+}
+
+// refresh downloads the key set. The caller must hold k.mu.
+func (k *KeySet) refresh(ctx context.Context) error {
+	k.lastFetched = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return fmt.Errorf("jwks: can't create request: %v", err)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("jwks: fetching keys failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks: fetching keys returned %s", resp.Status)
+	}
+
+	var set jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("jwks: failed to decode keys: %v", err)
+	}
+
+	expiry := time.Now().Add(defaultTTL)
+	if _, exp, err := cachecontrol.CachableResponse(req, resp, cachecontrol.Options{}); err == nil && !exp.IsZero() {
+		expiry = exp
+	}
+
+	k.keys = set.Keys
+	k.expiry = expiry
+	return nil
+}
+
+// containsKey reports whether keys contains a key with the given ID.
+func containsKey(keys []jose.JSONWebKey, keyID string) bool {
+	for _, key := range keys {
+		if key.KeyID == keyID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+
+	"google-calendar-api/utils"
+
+	"golang.org/x/oauth2"
+)
+
+// googleIssuerURL is Google's OpenID Connect issuer.
+const googleIssuerURL = "https://accounts.google.com"
+
+// defaultGoogleRevocationURL is used when the discovery document does not advertise one.
+const defaultGoogleRevocationURL = "https://oauth2.googleapis.com/revoke"
+
+// newGoogle configures Google sign-in from GOOGLE_CLIENT_ID, GOOGLE_CLIENT_SECRET,
+// GOOGLE_REDIRECT_URL and the optional GOOGLE_ISSUER_URL (or OIDC_ISSUER_URL),
+// which can point at a local fake identity provider.
+// Sign-in only asks for identity; Calendar scopes are requested incrementally.
+func newGoogle(ctx context.Context, name string) (*Provider, error) {
+	p, err := discover(ctx, settings{
+		name:         name,
+		displayName:  utils.GetEnv(envKey(name, "DISPLAY_NAME"), "Google"),
+		issuerURL:    utils.GetEnv(envKey(name, "ISSUER_URL"), utils.GetEnv("OIDC_ISSUER_URL", googleIssuerURL)),
+		clientID:     utils.GetEnv(envKey(name, "CLIENT_ID"), ""),
+		clientSecret: utils.GetEnv(envKey(name, "CLIENT_SECRET"), ""),
+		redirectURL:  utils.GetEnv(envKey(name, "REDIRECT_URL"), ""),
+		scopes: []string{
+			"openid",  // Required for obtaining ID Token
+			"email",   // Access to user's email
+			"profile", // Access to user's profile information
+		},
+	}, TypeGoogle, googleClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.RevocationURL == "" {
+		p.RevocationURL = defaultGoogleRevocationURL
+	}
+	p.AuthParams = []oauth2.AuthCodeOption{
+		oauth2.AccessTypeOffline, // Ask for a refresh token so API access outlives the access token
+		oauth2.SetAuthURLParam("include_granted_scopes", "true"),
+	}
+	return p, nil
+}
+
+// googleClaims maps Google ID token claims.
+func googleClaims(claims map[string]interface{}) Profile {
+	return Profile{
+		Subject:       stringClaim(claims, "sub"),
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: boolClaim(claims, "email_verified"),
+		Name:          stringClaim(claims, "name"),
+		Picture:       stringClaim(claims, "picture"),
+		HostedDomain:  stringClaim(claims, "hd"),
+	}
+}
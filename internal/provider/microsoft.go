@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google-calendar-api/utils"
+)
+
+// newMicrosoft configures Microsoft Entra ID sign-in from MICROSOFT_CLIENT_ID,
+// MICROSOFT_CLIENT_SECRET, MICROSOFT_REDIRECT_URL and MICROSOFT_TENANT_ID.
+// A specific tenant is required: the multi-tenant "common" endpoint publishes an
+// issuer template that cannot be verified.
+func newMicrosoft(ctx context.Context, name string) (*Provider, error) {
+	tenant := utils.GetEnv(envKey(name, "TENANT_ID"), "")
+	if tenant == "" {
+		return nil, fmt.Errorf("provider %s: %s is required", name, envKey(name, "TENANT_ID"))
+	}
+
+	return discover(ctx, settings{
+		name:         name,
+		displayName:  utils.GetEnv(envKey(name, "DISPLAY_NAME"), "Microsoft"),
+		issuerURL:    "https://login.microsoftonline.com/" + tenant + "/v2.0",
+		clientID:     utils.GetEnv(envKey(name, "CLIENT_ID"), ""),
+		clientSecret: utils.GetEnv(envKey(name, "CLIENT_SECRET"), ""),
+		redirectURL:  utils.GetEnv(envKey(name, "REDIRECT_URL"), ""),
+		scopes:       []string{"openid", "email", "profile", "offline_access"},
+	}, TypeMicrosoft, microsoftClaims)
+}
+
+// microsoftClaims maps Microsoft Entra ID token claims. The object ID ("oid") is
+// stable across applications in the tenant, unlike the pairwise "sub". Only the
+// "email" claim is used as the address: "preferred_username" can be changed by
+// tenant users and is not an email. Entra does not send email_verified, so the
+// address counts as verified only if the optional xms_edov claim says its domain
+// is verified by the tenant, or it is listed in verified_primary_email or
+// verified_secondary_email.
+func microsoftClaims(claims map[string]interface{}) Profile {
+	subject := stringClaim(claims, "oid")
+	if subject == "" {
+		subject = stringClaim(claims, "sub")
+	}
+	email := stringClaim(claims, "email")
+	return Profile{
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: email != "" && (boolClaim(claims, "xms_edov") || listClaimContains(claims, "verified_primary_email", email) || listClaimContains(claims, "verified_secondary_email", email)),
+		Name:          stringClaim(claims, "name"),
+	}
+}
+
+// listClaimContains reports whether the named claim, a string or a list of strings,
+// contains the value, ignoring case.
+func listClaimContains(claims map[string]interface{}, name, value string) bool {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.EqualFold(v, value)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && strings.EqualFold(s, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
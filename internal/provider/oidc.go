@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google-calendar-api/utils"
+)
+
+// newGenericOIDC configures any OpenID Connect provider (e.g. Keycloak) through
+// discovery. For a provider named "keycloak" it reads KEYCLOAK_ISSUER_URL,
+// KEYCLOAK_CLIENT_ID, KEYCLOAK_CLIENT_SECRET and KEYCLOAK_REDIRECT_URL, plus the
+// optional KEYCLOAK_DISPLAY_NAME, KEYCLOAK_SCOPES and KEYCLOAK_{EMAIL,NAME,PICTURE}_CLAIM.
+func newGenericOIDC(ctx context.Context, name string) (*Provider, error) {
+	issuerURL := utils.GetEnv(envKey(name, "ISSUER_URL"), "")
+	if issuerURL == "" {
+		return nil, fmt.Errorf("provider %s: %s is required", name, envKey(name, "ISSUER_URL"))
+	}
+
+	scopes := strings.Fields(utils.GetEnv(envKey(name, "SCOPES"), "openid email profile offline_access"))
+
+	return discover(ctx, settings{
+		name:         name,
+		displayName:  utils.GetEnv(envKey(name, "DISPLAY_NAME"), name),
+		issuerURL:    issuerURL,
+		clientID:     utils.GetEnv(envKey(name, "CLIENT_ID"), ""),
+		clientSecret: utils.GetEnv(envKey(name, "CLIENT_SECRET"), ""),
+		redirectURL:  utils.GetEnv(envKey(name, "REDIRECT_URL"), ""),
+		scopes:       scopes,
+	}, TypeOIDC, genericClaims(
+		utils.GetEnv(envKey(name, "EMAIL_CLAIM"), "email"),
+		utils.GetEnv(envKey(name, "NAME_CLAIM"), "name"),
+		utils.GetEnv(envKey(name, "PICTURE_CLAIM"), "picture"),
+	))
+}
+
+// genericClaims returns a ClaimMapper reading the configured claim names.
+func genericClaims(emailClaim, nameClaim, pictureClaim string) ClaimMapper {
+	return func(claims map[string]interface{}) Profile {
+		return Profile{
+			Subject:       stringClaim(claims, "sub"),
+			Email:         stringClaim(claims, emailClaim),
+			EmailVerified: boolClaim(claims, "email_verified"),
+			Name:          stringClaim(claims, nameClaim),
+			Picture:       stringClaim(claims, pictureClaim),
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package provider configures the OpenID Connect identity providers users can sign in with.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google-calendar-api/internal/jwks"
+
+	"github.com/coreos/go-oidc"
+	"golang.org/x/oauth2"
+)
+
+// Profile is the identity information mapped from a provider's ID token claims.
+type Profile struct {
+	Subject       string // Stable, provider-unique user identifier
+	Email         string // Email address
+	EmailVerified bool   // Whether the provider vouches for the email address
+	Name          string // Display name
+	Picture       string // Profile picture URL
+	HostedDomain  string // Google Workspace domain ("hd" claim), if any
+}
+
+// ClaimMapper maps the raw claims of a verified ID token to a Profile.
+type ClaimMapper func(claims map[string]interface{}) Profile
+
+// Provider is a configured OpenID Connect identity provider.
+type Provider struct {
+	Name          string                  // Name used in routes, e.g. "google" in /auth/google/login
+	DisplayName   string                  // Name shown on the login page
+	Type          string                  // "google", "microsoft" or "oidc"
+	OAuth         *oauth2.Config          // OAuth2 client configuration
+	Verifier      *oidc.IDTokenVerifier   // Verifies ID tokens against the provider's cached keys
+	RevocationURL string                  // Token revocation endpoint; empty if unsupported
+	AuthParams    []oauth2.AuthCodeOption // Extra parameters sent with every authorization request
+
+	mapClaims ClaimMapper
+}
+
+// Profile maps the claims of an already verified ID token to a Profile.
+func (p *Provider) Profile(idToken *oidc.IDToken) (Profile, error) {
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Profile{}, err
+	}
+	profile := p.mapClaims(claims)
+	if profile.Subject == "" {
+		profile.Subject = idToken.Subject
+	}
+	return profile, nil
+}
+
+// settings are the values every provider type needs.
+type settings struct {
+	name         string
+	displayName  string
+	issuerURL    string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+}
+
+// discover runs OIDC discovery for the issuer and builds a Provider with a cached key set.
+func discover(ctx context.Context, s settings, providerType string, mapClaims ClaimMapper) (*Provider, error) {
+	if s.clientID == "" || s.redirectURL == "" {
+		return nil, fmt.Errorf("provider %s: client ID and redirect URL are required", s.name)
+	}
+
+	oidcProvider, err := oidc.NewProvider(ctx, s.issuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: OIDC discovery for %s failed: %w", s.name, s.issuerURL, err)
+	}
+
+	var discovery struct {
+		JWKSURL       string `json:"jwks_uri"`
+		RevocationURL string `json:"revocation_endpoint"`
+	}
+	if err := oidcProvider.Claims(&discovery); err != nil {
+		return nil, fmt.Errorf("provider %s: failed to read OIDC discovery document: %w", s.name, err)
+	}
+
+	config := &oauth2.Config{
+		ClientID:     s.clientID,
+		ClientSecret: s.clientSecret,
+		RedirectURL:  s.redirectURL,
+		Scopes:       s.scopes,
+		Endpoint:     oidcProvider.Endpoint(),
+	}
+
+	keySet := jwks.NewKeySet(discovery.JWKSURL, nil)
+
+	return &Provider{
+		Name:          s.name,
+		DisplayName:   s.displayName,
+		Type:          providerType,
+		OAuth:         config,
+		Verifier:      oidc.NewVerifier(s.issuerURL, keySet, &oidc.Config{ClientID: s.clientID}),
+		RevocationURL: discovery.RevocationURL,
+		mapClaims:     mapClaims,
+	}, nil
+}
+
+// stringClaim returns the named claim if it is a string.
+func stringClaim(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// boolClaim returns the named claim as a boolean. Some providers send "true"/"false" strings.
+func boolClaim(claims map[string]interface{}, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
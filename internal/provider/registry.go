@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google-calendar-api/utils"
+
+	"golang.org/x/oauth2"
+)
+
+// Provider types.
+const (
+	TypeGoogle    = "google"
+	TypeMicrosoft = "microsoft"
+	TypeOIDC      = "oidc"
+)
+
+// Registry holds the configured providers by name.
+type Registry struct {
+	providers map[string]*Provider
+	order     []string
+}
+
+// NewRegistry creates a registry from already configured providers.
+func NewRegistry(providers ...*Provider) *Registry {
+	r := &Registry{providers: make(map[string]*Provider)}
+	for _, p := range providers {
+		r.providers[p.Name] = p
+		r.order = append(r.order, p.Name)
+	}
+	return r
+}
+
+// LoadFromEnv configures the providers listed in AUTH_PROVIDERS (default "google").
+// Each provider's type is read from <NAME>_TYPE and defaults to "google" or
+// "microsoft" for providers of that name and to "oidc" otherwise.
+func LoadFromEnv(ctx context.Context) (*Registry, error) {
+	var providers []*Provider
+	for _, name := range strings.Split(utils.GetEnv("AUTH_PROVIDERS", "google"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		var p *Provider
+		var err error
+		switch providerType := utils.GetEnv(envKey(name, "TYPE"), defaultType(name)); providerType {
+		case TypeGoogle:
+			p, err = newGoogle(ctx, name)
+		case TypeMicrosoft:
+			p, err = newMicrosoft(ctx, name)
+		case TypeOIDC:
+			p, err = newGenericOIDC(ctx, name)
+		default:
+			err = fmt.Errorf("provider %s: unknown type %q", name, providerType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no identity providers configured in AUTH_PROVIDERS")
+	}
+	return NewRegistry(providers...), nil
+}
+
+// Get returns the provider with the given name.
+func (r *Registry) Get(name string) (*Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// All returns the providers in configuration order.
+func (r *Registry) All() []*Provider {
+	all := make([]*Provider, 0, len(r.order))
+	for _, name := range r.order {
+		all = append(all, r.providers[name])
+	}
+	return all
+}
+
+// OAuthConfig returns the OAuth2 configuration of the named provider.
+// It satisfies the lookup used by the token manager.
+func (r *Registry) OAuthConfig(name string) (*oauth2.Config, bool) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, false
+	}
+	return p.OAuth, true
+}
+
+// defaultType infers a provider's type from its name.
+func defaultType(name string) string {
+	switch name {
+	case TypeGoogle, TypeMicrosoft:
+		return name
+	}
+	return TypeOIDC
+}
+
+// envKey builds the environment variable name for a provider setting, e.g. GOOGLE_CLIENT_ID.
+func envKey(name, setting string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + setting
+}
@@ -0,0 +1,98 @@
+// Package ratelimit throttles requests with token buckets.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Limit allows Requests requests per Per period, with bursts of up to Requests.
+// Tokens are refilled continuously at Requests/Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses a limit written as "<requests>/<duration>", e.g. "120/1m".
+func ParseLimit(value string) (Limit, error) {
+	requests, per, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q must look like 120/1m", value)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid request count", value)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid period", value)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// rate returns the refill rate in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed    bool          // Whether the request may proceed
+	Limit      int           // Bucket capacity
+	Remaining  int           // Whole tokens left after this request
+	Reset      time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // Time until a token is available; zero if allowed
+}
+
+// Limiter takes tokens from buckets identified by key.
+type Limiter interface {
+	// Allow takes one token from the key's bucket if one is available.
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// NewLimiter returns the limiter named by kind: "memory" (the default) or "postgres",
+// which shares buckets between instances using the same database.
+func NewLimiter(kind string, db *gorm.DB) (Limiter, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryLimiter(), nil
+	case "postgres":
+		return NewPostgresLimiter(db), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", kind)
+	}
+}
+
+// take refills a bucket that held tokens at last up to now and tries to take one token.
+// It returns the result and the number of tokens left in the bucket.
+func take(tokens float64, last, now time.Time, limit Limit) (Result, float64) {
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+
+	elapsed := now.Sub(last).Seconds()
+	if elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	result := Result{Limit: limit.Requests}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = seconds((capacity - tokens) / rate)
+	return result, tokens
+}
+
+// seconds converts a number of seconds to a Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
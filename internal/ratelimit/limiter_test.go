@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "120/1m", want: Limit{Requests: 120, Per: time.Minute}},
+		{value: " 5/30s ", want: Limit{Requests: 5, Per: 30 * time.Second}},
+		{value: "1000/1h", want: Limit{Requests: 1000, Per: time.Hour}},
+		{value: "120", wantErr: true},
+		{value: "/1m", wantErr: true},
+		{value: "0/1m", wantErr: true},
+		{value: "-1/1m", wantErr: true},
+		{value: "ten/1m", wantErr: true},
+		{value: "120/", wantErr: true},
+		{value: "120/0s", wantErr: true},
+		{value: "120/-1m", wantErr: true},
+		{value: "120/minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	limit := Limit{Requests: 10, Per: 10 * time.Second} // One token per second
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		tokens     float64
+		last       time.Time
+		want       Result
+		wantTokens float64
+	}{
+		{
+			name:       "full bucket",
+			tokens:     10,
+			last:       now,
+			want:       Result{Allowed: true, Limit: 10, Remaining: 9, Reset: time.Second},
+			wantTokens: 9,
+		},
+		{
+			name:       "last token",
+			tokens:     1,
+			last:       now,
+			want:       Result{Allowed: true, Limit: 10, Remaining: 0, Reset: 10 * time.Second},
+			wantTokens: 0,
+		},
+		{
+			name:       "empty bucket",
+			tokens:     0,
+			last:       now,
+			want:       Result{Limit: 10, Remaining: 0, Reset: 10 * time.Second, RetryAfter: time.Second},
+			wantTokens: 0,
+		},
+		{
+			name:       "partial token",
+			tokens:     0.5,
+			last:       now,
+			want:       Result{Limit: 10, Remaining: 0, Reset: 9500 * time.Millisecond, RetryAfter: 500 * time.Millisecond},
+			wantTokens: 0.5,
+		},
+		{
+			name:       "refilled since last request",
+			tokens:     0,
+			last:       now.Add(-3 * time.Second),
+			want:       Result{Allowed: true, Limit: 10, Remaining: 2, Reset: 8 * time.Second},
+			wantTokens: 2,
+		},
+		{
+			name:       "refill capped at capacity",
+			tokens:     0,
+			last:       now.Add(-time.Hour),
+			want:       Result{Allowed: true, Limit: 10, Remaining: 9, Reset: time.Second},
+			wantTokens: 9,
+		},
+		{
+			name:       "clock behind last request",
+			tokens:     0,
+			last:       now.Add(time.Minute),
+			want:       Result{Limit: 10, Remaining: 0, Reset: 10 * time.Second, RetryAfter: time.Second},
+			wantTokens: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, tokens := take(tt.tokens, tt.last, now, limit)
+			if got != tt.want {
+				t.Errorf("take = %+v, want %+v", got, tt.want)
+			}
+			if tokens != tt.wantTokens {
+				t.Errorf("tokens left = %v, want %v", tokens, tt.wantTokens)
+			}
+		})
+	}
+}
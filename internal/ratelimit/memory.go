@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often idle buckets are dropped.
+const pruneInterval = time.Minute
+
+// bucket is the state of one token bucket.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // When the bucket will be full again and can be forgotten
+}
+
+// MemoryLimiter keeps buckets in process memory, so each instance enforces its own limits.
+type MemoryLimiter struct {
+	mu         sync.Mutex
+	buckets    map[string]*bucket
+	lastPruned time.Time
+}
+
+// NewMemoryLimiter creates an in-memory Limiter.
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket)}
+}
+
+func (m *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now}
+		m.buckets[key] = b
+	}
+
+	result, tokens := take(b.tokens, b.updated, now, limit)
+	b.tokens = tokens
+	b.updated = now
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// prune drops buckets that have refilled completely, since a missing bucket
+// behaves like a full one. The caller must hold m.mu.
+func (m *MemoryLimiter) prune(now time.Time) {
+	if now.Sub(m.lastPruned) < pruneInterval {
+		return
+	}
+	m.lastPruned = now
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
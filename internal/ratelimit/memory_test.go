@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	limit := Limit{Requests: 3, Per: time.Hour}
+	ctx := context.Background()
+
+	for i := 0; i < limit.Requests; i++ {
+		result, err := limiter.Allow(ctx, "user:a", limit)
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if !result.Allowed || result.Remaining != limit.Requests-1-i {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i+1, result, limit.Requests-1-i)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, "user:a", limit)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if result.Allowed {
+		t.Fatal("request over the limit was allowed")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > limit.Per/time.Duration(limit.Requests) {
+		t.Errorf("RetryAfter = %s, want up to the time to refill one token", result.RetryAfter)
+	}
+
+	// Buckets are independent per key
+	if result, _ := limiter.Allow(ctx, "user:b", limit); !result.Allowed {
+		t.Error("another key's request was rejected")
+	}
+}
+
+func TestMemoryLimiterRefills(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	limit := Limit{Requests: 1, Per: 20 * time.Millisecond}
+	ctx := context.Background()
+
+	if result, _ := limiter.Allow(ctx, "ip:192.0.2.1", limit); !result.Allowed {
+		t.Fatal("first request was rejected")
+	}
+	result, _ := limiter.Allow(ctx, "ip:192.0.2.1", limit)
+	if result.Allowed {
+		t.Fatal("second request was allowed before the bucket refilled")
+	}
+
+	time.Sleep(result.RetryAfter + 5*time.Millisecond)
+	if result, _ := limiter.Allow(ctx, "ip:192.0.2.1", limit); !result.Allowed {
+		t.Errorf("request after Retry-After was rejected: %+v", result)
+	}
+}
+
+func TestMemoryLimiterPrunesFullBuckets(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	limit := Limit{Requests: 10, Per: time.Millisecond}
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "user:a", limit)
+	time.Sleep(5 * time.Millisecond)
+
+	// Force the next call to prune
+	limiter.lastPruned = time.Time{}
+	limiter.Allow(ctx, "user:b", limit)
+
+	if _, ok := limiter.buckets["user:a"]; ok {
+		t.Error("refilled bucket was not pruned")
+	}
+	if _, ok := limiter.buckets["user:b"]; !ok {
+		t.Error("bucket in use was pruned")
+	}
+}
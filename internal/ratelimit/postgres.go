@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google-calendar-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idleBucketTTL is how long an unused bucket row is kept. Any bucket whose period
+// is shorter has refilled completely by then and behaves like a missing row.
+const idleBucketTTL = 24 * time.Hour
+
+// PostgresLimiter keeps buckets in the rate_limit_buckets table so that every
+// instance sharing the database enforces the same limits.
+type PostgresLimiter struct {
+	db *gorm.DB
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+// NewPostgresLimiter creates a Limiter backed by the given database.
+func NewPostgresLimiter(db *gorm.DB) *PostgresLimiter {
+	return &PostgresLimiter{db: db}
+}
+
+func (p *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	p.prune(ctx)
+
+	var result Result
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// Create a full bucket on first use, then lock it so concurrent requests take turns
+		seed := models.RateLimitBucket{Key: key, Tokens: float64(limit.Requests), UpdatedAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error; err != nil {
+			return err
+		}
+
+		var b models.RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&b).Error; err != nil {
+			return err
+		}
+
+		var tokens float64
+		result, tokens = take(b.Tokens, b.UpdatedAt, now, limit)
+		return tx.Model(&b).Updates(map[string]interface{}{"tokens": tokens, "updated_at": now}).Error
+	})
+	return result, err
+}
+
+// prune deletes idle buckets, at most once per pruneInterval per instance.
+func (p *PostgresLimiter) prune(ctx context.Context) {
+	p.mu.Lock()
+	now := time.Now()
+	due := now.Sub(p.lastPruned) >= pruneInterval
+	if due {
+		p.lastPruned = now
+	}
+	p.mu.Unlock()
+	if !due {
+		return
+	}
+
+	if err := p.db.WithContext(ctx).Where("updated_at < ?", now.Add(-idleBucketTTL)).Delete(&models.RateLimitBucket{}).Error; err != nil {
+		log.Println("⚠️ Failed to prune rate limit buckets:", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Package refresher proactively refreshes OAuth tokens that are about to expire,
+// so scheduled jobs can call Google APIs without the user being present.
+package refresher
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"google-calendar-api/internal/tokens"
+	"google-calendar-api/models"
+	"google-calendar-api/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Config controls how the refresher scans for and refreshes tokens.
+type Config struct {
+	Interval    time.Duration // Time between scans
+	Window      time.Duration // Refresh tokens expiring within this window
+	Concurrency int           // Maximum refreshes running at once
+	BatchSize   int           // Maximum identities refreshed per scan
+}
+
+// ConfigFromEnv reads the refresher configuration from TOKEN_REFRESH_INTERVAL,
+// TOKEN_REFRESH_WINDOW, TOKEN_REFRESH_CONCURRENCY and TOKEN_REFRESH_BATCH_SIZE.
+func ConfigFromEnv() Config {
+	return Config{
+		Interval:    utils.GetEnvDuration("TOKEN_REFRESH_INTERVAL", 5*time.Minute),
+		Window:      utils.GetEnvDuration("TOKEN_REFRESH_WINDOW", 15*time.Minute),
+		Concurrency: utils.GetEnvInt("TOKEN_REFRESH_CONCURRENCY", 4),
+		BatchSize:   utils.GetEnvInt("TOKEN_REFRESH_BATCH_SIZE", 500),
+	}
+}
+
+// Result describes the outcome of one refresh attempt.
+type Result struct {
+	IdentityID     uuid.UUID     // Identity whose token was refreshed
+	Duration       time.Duration // Time taken by the attempt
+	Err            error         // Non-nil if the refresh failed
+	ReauthRequired bool          // Whether the failure means the user must consent again
+}
+
+// Metrics receives the outcome of every refresh attempt and scan.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	ObserveRefresh(Result)
+	ObserveScan(candidates int, duration time.Duration)
+}
+
+// NopMetrics discards all observations.
+type NopMetrics struct{}
+
+func (NopMetrics) ObserveRefresh(Result)          {}
+func (NopMetrics) ObserveScan(int, time.Duration) {}
+
+// Refresher periodically refreshes tokens nearing expiry.
+type Refresher struct {
+	db      *gorm.DB
+	tokens  *tokens.Manager
+	config  Config
+	metrics Metrics
+}
+
+// New creates a Refresher. If metrics is nil, observations are discarded.
+func New(db *gorm.DB, manager *tokens.Manager, config Config, metrics Metrics) *Refresher {
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+	if config.BatchSize < 1 {
+		config.BatchSize = 500
+	}
+	if metrics == nil {
+		metrics = NopMetrics{}
+	}
+	return &Refresher{db: db, tokens: manager, config: config, metrics: metrics}
+}
+
+// Run scans for expiring tokens every Interval until ctx is cancelled.
+func (r *Refresher) Run(ctx context.Context) {
+	log.Printf("🔄 Token refresher started (interval %s, window %s, concurrency %d)",
+		r.config.Interval, r.config.Window, r.config.Concurrency)
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("🔄 Token refresher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce refreshes every token that expires within the configured window. Identities
+// whose last refresh failed are skipped until their backoff has passed.
+func (r *Refresher) RunOnce(ctx context.Context) {
+	start := time.Now()
+
+	var identityIDs []uuid.UUID
+	err := r.db.WithContext(ctx).Model(&models.Identity{}).
+		Where("refresh_token <> '' AND needs_reauth = ? AND expires_at < ?", false, start.Add(r.config.Window)).
+		Where("next_refresh_at IS NULL OR next_refresh_at <= ?", start).
+		Order("expires_at").
+		Limit(r.config.BatchSize).
+		Pluck("id", &identityIDs).Error
+	if err != nil {
+		log.Println("❌ Token refresher failed to query identities:", err)
+		return
+	}
+
+	sem := make(chan struct{}, r.config.Concurrency)
+	var wg sync.WaitGroup
+	for _, id := range identityIDs {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(identityID uuid.UUID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.refresh(ctx, identityID)
+		}(id)
+	}
+	wg.Wait()
+
+	r.metrics.ObserveScan(len(identityIDs), time.Since(start))
+}
+
+// refresh refreshes a single identity's token. Failures are recorded on the identity by the token manager.
+func (r *Refresher) refresh(ctx context.Context, identityID uuid.UUID) {
+	start := time.Now()
+	_, err := r.tokens.RefreshIfExpiring(ctx, identityID, r.config.Window)
+
+	result := Result{
+		IdentityID:     identityID,
+		Duration:       time.Since(start),
+		Err:            err,
+		ReauthRequired: errors.Is(err, tokens.ErrReauthRequired),
+	}
+	if result.ReauthRequired {
+		log.Printf("⚠️ Identity %s needs to reauthorize: %v", identityID, err)
+	} else if err != nil {
+		log.Printf("❌ Background refresh failed for identity %s: %v", identityID, err)
+	}
+
+	r.metrics.ObserveRefresh(result)
+}
@@ -0,0 +1,111 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on restart,
+// so it is intended for development and single-instance deployments.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]models.Session
+}
+
+// NewMemoryStore creates an empty in-memory Store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]models.Session)}
+}
+
+func (m *MemoryStore) Create(ctx context.Context, s *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+	m.sessions[s.ID] = *s
+	return nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (m *MemoryStore) Touch(ctx context.Context, id string, lastSeen, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.LastSeenAt = lastSeen
+	s.ExpiresAt = expiresAt
+	m.sessions[id] = s
+	return nil
+}
+
+func (m *MemoryStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var sessions []models.Session
+	for id, s := range m.sessions {
+		if s.UserID != userID {
+			continue
+		}
+		if now.After(s.ExpiresAt) {
+			// Expired sessions can no longer be used; drop them while we are here
+			delete(m.sessions, id)
+			continue
+		}
+		if s.Active(now) {
+			sessions = append(sessions, s)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (m *MemoryStore) Revoke(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil
+	}
+	if s.RevokedAt == nil {
+		now := time.Now()
+		s.RevokedAt = &now
+		m.sessions[id] = s
+	}
+	return nil
+}
+
+func (m *MemoryStore) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, s := range m.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+			m.sessions[id] = s
+		}
+	}
+	return nil
+}
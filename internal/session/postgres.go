@@ -0,0 +1,64 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PostgresStore keeps sessions in the sessions table.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a Store backed by the given database.
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (p *PostgresStore) Create(ctx context.Context, s *models.Session) error {
+	return p.db.WithContext(ctx).Create(s).Error
+}
+
+func (p *PostgresStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	var s models.Session
+	if err := p.db.WithContext(ctx).Where("id = ?", id).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (p *PostgresStore) Touch(ctx context.Context, id string, lastSeen, expiresAt time.Time) error {
+	return p.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_seen_at": lastSeen, "expires_at": expiresAt}).Error
+}
+
+func (p *PostgresStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := p.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (p *PostgresStore) Revoke(ctx context.Context, id string) error {
+	return p.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (p *PostgresStore) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	return p.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
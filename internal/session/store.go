@@ -0,0 +1,45 @@
+// Package session stores login sessions so they can be listed and revoked.
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a session does not exist.
+var ErrNotFound = errors.New("session not found")
+
+// Store persists login sessions.
+type Store interface {
+	// Create records a new session.
+	Create(ctx context.Context, s *models.Session) error
+	// Get returns the session with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (*models.Session, error)
+	// Touch records activity on a session and moves its expiry.
+	Touch(ctx context.Context, id string, lastSeen, expiresAt time.Time) error
+	// ListByUser returns the user's active sessions, most recently used first.
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+	// Revoke marks a single session as revoked.
+	Revoke(ctx context.Context, id string) error
+	// RevokeAll revokes every session belonging to the user.
+	RevokeAll(ctx context.Context, userID uuid.UUID) error
+}
+
+// NewStore returns the store named by kind: "postgres" (the default) or "memory".
+func NewStore(kind string, db *gorm.DB) (Store, error) {
+	switch kind {
+	case "", "postgres":
+		return NewPostgresStore(db), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown session store %q", kind)
+	}
+}
@@ -0,0 +1,278 @@
+// Package tokens keeps the OAuth tokens of linked identities fresh and persists every refresh.
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"google-calendar-api/internal/audit"
+	"google-calendar-api/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReauthRequired is matched (via errors.Is) by errors returned when the user
+// must go through the consent screen again for an identity, e.g. because the
+// refresh token was revoked or never granted.
+var ErrReauthRequired = errors.New("reauthorization required")
+
+// ReauthRequiredError reports that an identity's grant can no longer be refreshed.
+type ReauthRequiredError struct {
+	IdentityID    uuid.UUID // Identity whose grant is unusable
+	Provider      string    // Identity provider the user must consent at again
+	GrantedScopes string    // Space-separated scopes the unusable grant held
+	Err           error     // Underlying cause, if any
+}
+
+func (e *ReauthRequiredError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("identity %s must reauthorize", e.IdentityID)
+	}
+	return fmt.Sprintf("identity %s must reauthorize: %v", e.IdentityID, e.Err)
+}
+
+func (e *ReauthRequiredError) Unwrap() error { return e.Err }
+
+// Is makes errors.Is(err, ErrReauthRequired) match any ReauthRequiredError.
+func (e *ReauthRequiredError) Is(target error) bool { return target == ErrReauthRequired }
+
+// refreshTimeout bounds the call to the provider's token endpoint, which is made while
+// the identity's row lock is held.
+const refreshTimeout = 10 * time.Second
+
+// Failed refreshes are retried by the background refresher after a backoff that
+// doubles with every consecutive failure, from retryBackoffBase up to retryBackoffMax.
+const (
+	retryBackoffBase = time.Minute
+	retryBackoffMax  = time.Hour
+)
+
+// ConfigLookup returns the OAuth2 configuration of the named identity provider.
+type ConfigLookup func(provider string) (*oauth2.Config, bool)
+
+// Manager hands out OAuth tokens for identities, refreshing them when needed.
+// Refreshes for the same identity are serialized, both within the process and,
+// through a row lock, across instances sharing the database, and every
+// refreshed token (including a rotated refresh token) is persisted.
+type Manager struct {
+	db        *gorm.DB
+	configFor ConfigLookup
+	audit     *audit.Logger
+
+	locks sync.Map // identity uuid.UUID -> *sync.Mutex
+}
+
+// NewManager creates a Manager that refreshes each identity's tokens with the
+// OAuth2 configuration of its provider. Every refresh attempt is written to the audit log.
+func NewManager(db *gorm.DB, configFor ConfigLookup, auditLog *audit.Logger) *Manager {
+	return &Manager{db: db, configFor: configFor, audit: auditLog}
+}
+
+// TokenSource returns a token source for the identity that refreshes through the Manager.
+// The returned source caches the token until it expires.
+func (m *Manager) TokenSource(ctx context.Context, identityID uuid.UUID) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &identityTokenSource{ctx: ctx, m: m, identityID: identityID})
+}
+
+// Token returns a valid token for the identity, refreshing and persisting it if it has expired.
+func (m *Manager) Token(ctx context.Context, identityID uuid.UUID) (*oauth2.Token, error) {
+	return m.token(ctx, identityID, 0)
+}
+
+// RefreshIfExpiring refreshes the identity's token if it expires within the given window
+// and persists the result. It is used to refresh tokens ahead of time.
+func (m *Manager) RefreshIfExpiring(ctx context.Context, identityID uuid.UUID, within time.Duration) (*oauth2.Token, error) {
+	return m.token(ctx, identityID, within)
+}
+
+// token returns the stored token if it remains valid for at least minValidity,
+// otherwise it refreshes it. Failures are recorded on the identity; an unusable
+// grant also flags the identity as needing reconsent.
+func (m *Manager) token(ctx context.Context, identityID uuid.UUID, minValidity time.Duration) (*oauth2.Token, error) {
+	token, err := m.refreshLocked(ctx, identityID, minValidity)
+	// A caller that gave up, e.g. a closed request, says nothing about the grant
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && ctx.Err() == nil && !errors.Is(err, context.Canceled) {
+		m.recordFailure(ctx, identityID, err)
+	}
+	return token, err
+}
+
+// refreshLocked returns the stored token if it is still valid, without taking any lock.
+// Otherwise it serializes with other refreshes of the identity, reloads it under a row
+// lock and refreshes it unless another request or instance has just done so.
+func (m *Manager) refreshLocked(ctx context.Context, identityID uuid.UUID, minValidity time.Duration) (*oauth2.Token, error) {
+	var stored models.Identity
+	if err := m.db.WithContext(ctx).Where("id = ?", identityID).First(&stored).Error; err != nil {
+		return nil, err
+	}
+	if current := storedToken(&stored); usable(current, minValidity) {
+		return current, nil
+	}
+
+	mu := m.lock(identityID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	var result *oauth2.Token
+	var refreshedFor *models.Identity
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Reload under a row lock: another request or instance may have refreshed already
+		var identity models.Identity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", identityID).First(&identity).Error; err != nil {
+			return err
+		}
+
+		current := storedToken(&identity)
+		if usable(current, minValidity) {
+			result = current
+			return nil
+		}
+
+		if current.RefreshToken == "" {
+			return reauthRequired(&identity, errors.New("no refresh token stored"))
+		}
+
+		config, ok := m.configFor(identity.Provider)
+		if !ok {
+			return fmt.Errorf("provider %q is not configured", identity.Provider)
+		}
+
+		// The row stays locked until the transaction ends, so bound the network call
+		refreshCtx, cancel := context.WithTimeout(ctx, refreshTimeout)
+		refreshed, err := config.TokenSource(refreshCtx, &oauth2.Token{RefreshToken: current.RefreshToken}).Token()
+		cancel()
+		if err != nil {
+			var retrieveErr *oauth2.RetrieveError
+			if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+				return reauthRequired(&identity, err)
+			}
+			return fmt.Errorf("refresh failed: %w", err)
+		}
+
+		updates := map[string]interface{}{
+			"access_token":       models.EncryptedString(refreshed.AccessToken),
+			"expires_at":         refreshed.Expiry,
+			"refresh_failures":   0,
+			"last_refresh_error": "",
+			"needs_reauth":       false,
+			"next_refresh_at":    nil,
+		}
+		if scopes, ok := refreshed.Extra("scope").(string); ok && scopes != "" {
+			updates["granted_scopes"] = scopes
+		}
+		// Providers may rotate the refresh token; keep the new one
+		if refreshed.RefreshToken != "" && refreshed.RefreshToken != current.RefreshToken {
+			updates["refresh_token"] = models.EncryptedString(refreshed.RefreshToken)
+		}
+		if err := tx.Model(&identity).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to persist refreshed token: %w", err)
+		}
+
+		result = refreshed
+		refreshedFor = &identity
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if refreshedFor != nil {
+		m.audit.Record(ctx, audit.Entry{
+			ActorID:    refreshedFor.UserID,
+			ActorEmail: refreshedFor.Email,
+			Action:     audit.ActionTokenRefresh,
+			Target:     identityID.String(),
+			Outcome:    audit.Success,
+		})
+	}
+	return result, nil
+}
+
+// reauthRequired returns the error reporting that the identity must consent again.
+func reauthRequired(identity *models.Identity, cause error) *ReauthRequiredError {
+	return &ReauthRequiredError{
+		IdentityID:    identity.ID,
+		Provider:      identity.Provider,
+		GrantedScopes: identity.GrantedScopes,
+		Err:           cause,
+	}
+}
+
+// storedToken returns the identity's stored token.
+func storedToken(identity *models.Identity) *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  identity.AccessToken.String(),
+		RefreshToken: identity.RefreshToken.String(),
+		Expiry:       identity.ExpiresAt,
+		TokenType:    "Bearer",
+	}
+}
+
+// usable reports whether the token is valid for at least minValidity.
+func usable(token *oauth2.Token, minValidity time.Duration) bool {
+	return token.Valid() && time.Until(token.Expiry) >= minValidity
+}
+
+// recordFailure stores the refresh error on the identity, schedules the next background
+// attempt, flags grants that need reconsent and audits the failed refresh.
+func (m *Manager) recordFailure(ctx context.Context, identityID uuid.UUID, cause error) {
+	var identity models.Identity
+	m.db.WithContext(ctx).Select("user_id", "email", "refresh_failures").Where("id = ?", identityID).First(&identity)
+
+	updates := map[string]interface{}{
+		"refresh_failures":   gorm.Expr("refresh_failures + 1"),
+		"last_refresh_error": cause.Error(),
+		"next_refresh_at":    time.Now().Add(retryBackoff(identity.RefreshFailures + 1)),
+	}
+	if errors.Is(cause, ErrReauthRequired) {
+		updates["needs_reauth"] = true
+	}
+	if err := m.db.WithContext(ctx).Model(&models.Identity{}).Where("id = ?", identityID).Updates(updates).Error; err != nil {
+		log.Println("❌ Failed to record token refresh failure:", err)
+	}
+
+	m.audit.Record(ctx, audit.Entry{
+		ActorID:    identity.UserID,
+		ActorEmail: identity.Email,
+		Action:     audit.ActionTokenRefresh,
+		Target:     identityID.String(),
+		Outcome:    audit.Failure,
+		Detail:     cause.Error(),
+	})
+}
+
+// retryBackoff returns how long to wait before retrying after the given number of
+// consecutive failures.
+func retryBackoff(failures int) time.Duration {
+	backoff := retryBackoffBase
+	for i := 1; i < failures && backoff < retryBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > retryBackoffMax {
+		backoff = retryBackoffMax
+	}
+	return backoff
+}
+
+// lock returns the mutex serializing refreshes for the identity.
+func (m *Manager) lock(identityID uuid.UUID) *sync.Mutex {
+	mu, _ := m.locks.LoadOrStore(identityID, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// identityTokenSource adapts Manager.Token to oauth2.TokenSource.
+type identityTokenSource struct {
+	ctx        context.Context
+	m          *Manager
+	identityID uuid.UUID
+}
+
+func (s *identityTokenSource) Token() (*oauth2.Token, error) {
+	return s.m.Token(s.ctx, s.identityID)
+}
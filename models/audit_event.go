@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent is a persistent record of an authentication or security-relevant action.
+type AuditEvent struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ActorID    *uuid.UUID `gorm:"type:uuid;index" json:"actor_id,omitempty"` // User who performed the action; nil if unknown
+	ActorEmail string     `json:"actor_email,omitempty"`                     // Email of the actor at the time of the action
+	Action     string     `gorm:"index" json:"action"`                       // What happened, e.g. "auth.login"
+	Target     string     `json:"target,omitempty"`                          // What the action applied to, e.g. an event or session ID
+	Outcome    string     `gorm:"index" json:"outcome"`                      // "success", "failure" or "denied"
+	Detail     string     `json:"detail,omitempty"`                          // Error or extra context
+	IP         string     `json:"ip,omitempty"`                              // Client IP address
+	UserAgent  string     `json:"user_agent,omitempty"`                      // Client user agent
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`                   // Timestamp of the action
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeniedSignIn records a sign-in attempt rejected by the access policy, for auditing.
+type DeniedSignIn struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Provider     string    `json:"provider"`                // Provider the attempt came through
+	Subject      string    `json:"subject"`                 // Provider's subject identifier
+	Email        string    `gorm:"index" json:"email"`      // Email address from the ID token
+	HostedDomain string    `json:"hosted_domain"`           // Workspace domain ("hd" claim), if any
+	Reason       string    `json:"reason"`                  // Machine-readable denial reason
+	Detail       string    `json:"detail"`                  // Human-readable explanation
+	IP           string    `json:"ip"`                      // Client IP address
+	UserAgent    string    `json:"user_agent"`              // Client user agent
+	CreatedAt    time.Time `gorm:"index" json:"created_at"` // Timestamp of the attempt
+}
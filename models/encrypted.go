@@ -0,0 +1,46 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"google-calendar-api/utils"
+)
+
+// EncryptedString is a string column that is envelope-encrypted with the
+// application keyring when written and decrypted when read.
+// Legacy plaintext values are read as-is and encrypted on their next save.
+type EncryptedString string
+
+// Value encrypts the string before it is written to the database.
+func (s EncryptedString) Value() (driver.Value, error) {
+	return utils.EncryptString(string(s))
+}
+
+// Scan decrypts a value read from the database.
+func (s *EncryptedString) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into EncryptedString", value)
+	}
+
+	plaintext, err := utils.DecryptString(raw)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// String returns the decrypted value.
+func (s EncryptedString) String() string {
+	return string(s)
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Identity is an account at an identity provider linked to a User.
+// A user can link several identities, e.g. two Google accounts, or a Google and
+// a Microsoft account. OAuth tokens are encrypted at rest and never serialized to JSON.
+type Identity struct {
+	ID           uuid.UUID       `gorm:"type:uuid;primaryKey" json:"id"`                                       // Unique Identity ID (UUID)
+	UserID       uuid.UUID       `gorm:"type:uuid;index;not null" json:"user_id"`                              // User the identity is linked to
+	Provider     string          `gorm:"uniqueIndex:idx_identities_provider_subject;not null" json:"provider"` // Identity provider name, e.g. "google"
+	Subject      string          `gorm:"uniqueIndex:idx_identities_provider_subject;not null" json:"-"`        // Provider's unique subject identifier
+	Email        string          `json:"email"`                                                                // Email address at the provider
+	AccessToken  EncryptedString `json:"-"`                                                                    // OAuth access token for API calls (encrypted at rest)
+	RefreshToken EncryptedString `json:"-"`                                                                    // OAuth refresh token to renew access (encrypted at rest)
+	ExpiresAt    time.Time       `json:"expires_at"`                                                           // Token expiration timestamp
+
+	GrantedScopes    string     `json:"granted_scopes"` // Space-separated OAuth scopes granted for this identity
+	NeedsReauth      bool       `json:"needs_reauth"`   // Set when the grant can no longer be refreshed and the user must consent again
+	RefreshFailures  int        `json:"-"`              // Consecutive failed token refreshes
+	LastRefreshError string     `json:"-"`              // Error from the most recent failed refresh
+	NextRefreshAt    *time.Time `json:"-"`              // Earliest time the background refresher retries after a failure
+
+	CreatedAt time.Time `json:"created_at"` // Timestamp of when the identity was linked
+	UpdatedAt time.Time `json:"updated_at"` // Timestamp of the last update
+}
+
+// MigrateLegacyIdentities creates an Identity for every user created before
+// identities existed, copying the provider account and tokens that used to be
+// stored on the users table, and then clears the copied token columns.
+// It is safe to run on every startup and returns the number of identities created.
+func MigrateLegacyIdentities(db *gorm.DB) (int64, error) {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&Identity{}) {
+		return 0, errors.New("identities table does not exist; run the server migrations first")
+	}
+	if !migrator.HasColumn(&User{}, "google_id") || !migrator.HasColumn(&User{}, "access_token") {
+		return 0, nil
+	}
+
+	// Copy the Google account and tokens the baseline schema kept on users
+	result := db.Exec(`INSERT INTO identities (id, user_id, provider, subject, email, access_token, refresh_token,
+			expires_at, granted_scopes, needs_reauth, refresh_failures, last_refresh_error, created_at, updated_at)
+		SELECT gen_random_uuid(), u.id, 'google', u.google_id, u.email, u.access_token, u.refresh_token,
+			u.expires_at, '', false, 0, '', NOW(), NOW()
+		FROM users u
+		WHERE u.google_id IS NOT NULL
+		  AND NOT EXISTS (SELECT 1 FROM identities i WHERE i.user_id = u.id)`)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	// The tokens now live on the identities; don't keep a second copy around
+	err := db.Exec(`UPDATE users SET access_token = NULL, refresh_token = NULL
+		WHERE access_token IS NOT NULL OR refresh_token IS NOT NULL`).Error
+	return result.RowsAffected, err
+}
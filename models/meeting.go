@@ -1,18 +1,26 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Meeting represents a scheduled meeting with details like title, description, time, and attendees.
 // It includes an associated Google Calendar event ID for synchronization.
 type Meeting struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`    // Unique meeting ID (Primary Key)
-	Title       string    `json:"title"`                   // Meeting title
-	Description string    `json:"description"`             // Meeting description or agenda
-	StartTime   time.Time `json:"start_time"`              // Meeting start time
-	EndTime     time.Time `json:"end_time"`                // Meeting end time
-	EventID     string    `json:"event_id"`                // Google Calendar Event ID
-	Attendees   string    `json:"attendees"`               // Comma-separated list of attendee emails
-	CreatedBy   string    `json:"created_by"`              // Email of the user who created the meeting
-	CreatedAt   time.Time `json:"created_at"`              // Timestamp of when the meeting was created
-	UpdatedAt   time.Time `json:"updated_at"`              // Timestamp of the last update
+	ID          uint      `gorm:"primaryKey" json:"id"`                                                 // Unique meeting ID (Primary Key)
+	UserID      uuid.UUID `gorm:"type:uuid;index" json:"user_id"`                                       // User who created the meeting
+	IdentityID  uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_meetings_identity_event" json:"identity_id"` // Linked Google account the event was created with
+	CalendarID  string    `gorm:"default:primary" json:"calendar_id"`                                   // Google Calendar the event belongs to
+	Title       string    `json:"title"`                                                                // Meeting title
+	Description string    `json:"description"`                                                          // Meeting description or agenda
+	StartTime   time.Time `gorm:"index" json:"start_time"`                                              // Meeting start time
+	EndTime     time.Time `json:"end_time"`                                                             // Meeting end time
+	EventID     string    `gorm:"uniqueIndex:idx_meetings_identity_event" json:"event_id"`              // Google Calendar Event ID
+	ETag        string    `gorm:"column:etag" json:"etag"`                                              // Google Calendar etag of the version last seen
+	Attendees   string    `json:"attendees"`                                                            // Comma-separated list of attendee emails
+	CreatedBy   string    `json:"created_by"`                                                           // Email of the user who created the meeting
+	CreatedAt   time.Time `json:"created_at"`                                                           // Timestamp of when the meeting was created
+	UpdatedAt   time.Time `json:"updated_at"`                                                           // Timestamp of the last update
 }
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalAccessToken is a long-lived, scoped API token a user creates for scripts and CI.
+// Only a SHA-256 hash of the token is stored; the token itself is shown once at creation.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;index" json:"user_id"` // Owner of the token
+	Name       string     `json:"name"`                           // Label chosen by the user
+	Prefix     string     `gorm:"uniqueIndex" json:"prefix"`      // Public lookup prefix embedded in the token
+	Hash       string     `json:"-"`                              // Hex SHA-256 of the full token
+	Scopes     string     `json:"-"`                              // Space-separated scopes, e.g. "events:read events:write"
+	CreatedAt  time.Time  `json:"created_at"`                     // Timestamp of creation
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`         // Timestamp of the last authenticated request
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`           // Nil for tokens that do not expire
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`           // Set when the token is revoked
+}
+
+// ScopeList returns the token's scopes as a list.
+func (t *PersonalAccessToken) ScopeList() []string {
+	return append([]string{}, strings.Fields(t.Scopes)...)
+}
+
+// HasScope reports whether the token was granted the scope.
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range strings.Fields(t.Scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the token has neither been revoked nor expired.
+func (t *PersonalAccessToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// RateLimitBucket is the persisted state of a rate limiting token bucket.
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey"` // Route group and user or client IP, e.g. "api:user:<uuid>"
+	Tokens    float64   // Tokens left at UpdatedAt
+	UpdatedAt time.Time `gorm:"index"` // Time of the last request
+}
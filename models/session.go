@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session represents a login session issued to a user.
+// Session tokens carry the session ID so that a session can be revoked server-side.
+type Session struct {
+	ID         string     `gorm:"primaryKey" json:"id"`           // Session ID embedded in the session token
+	UserID     uuid.UUID  `gorm:"type:uuid;index" json:"user_id"` // Owner of the session
+	IP         string     `json:"ip"`                             // Client IP address at login
+	UserAgent  string     `json:"user_agent"`                     // Client user agent at login
+	CreatedAt  time.Time  `json:"created_at"`                     // Timestamp of the login
+	LastSeenAt time.Time  `json:"last_seen_at"`                   // Timestamp of the last authenticated request
+	ExpiresAt  time.Time  `json:"expires_at"`                     // Expiry of the most recently issued token
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`           // Set when the session is logged out or revoked
+}
+
+// Active reports whether the session has neither been revoked nor expired.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
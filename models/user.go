@@ -1,22 +1,32 @@
 package models
 
 import (
-	"time"
-
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // User represents an authenticated user in the system.
-// It stores authentication details for Google OAuth integration.
+// A user signs in through one or more linked identities, which hold the
+// provider accounts and their OAuth tokens.
 type User struct {
 	gorm.Model
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"` // Unique User ID (UUID)
-	GoogleID     string    `gorm:"unique" json:"google_id"`                                   // Google OAuth ID (Unique)
-	Email        string    `gorm:"unique" json:"email"`                                       // User's email (Unique)
-	Name         string    `json:"name"`                                                      // User's full name
-	Picture      string    `json:"picture"`                                                   // Profile picture URL
-	AccessToken  string    `json:"access_token"`                                             // OAuth access token for API calls
-	RefreshToken string    `json:"refresh_token"`                                            // OAuth refresh token to renew access
-	ExpiresAt    time.Time `json:"expires_at"`                                               // Token expiration timestamp
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"` // Unique User ID (UUID)
+	Email             string     `gorm:"unique" json:"email"`                                       // User's primary email (Unique)
+	Name              string     `json:"name"`                                                      // User's full name
+	Picture           string     `json:"picture"`                                                   // Profile picture URL
+	Role              string     `gorm:"not null;default:member" json:"role"`                       // Access role: admin, member or viewer
+	DefaultCalendarID string     `gorm:"not null;default:primary" json:"default_calendar_id"`       // Google Calendar used when a request names none
+	Identities        []Identity `gorm:"foreignKey:UserID" json:"identities,omitempty"`             // Linked provider accounts
+}
+
+// User roles, from most to least privileged.
+const (
+	RoleAdmin  = "admin"  // Full access, including managing other users
+	RoleMember = "member" // Reads and writes calendar events
+	RoleViewer = "viewer" // Reads calendar events only
+)
+
+// ValidRole reports whether role is one of the defined roles.
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleMember || role == RoleViewer
 }
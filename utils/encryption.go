@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix marks values produced by Keyring.Encrypt.
+const encryptedPrefix = "enc:"
+
+// Keyring holds the versioned key-encryption keys (KEKs) that protect secrets at rest.
+//
+// Values are envelope-encrypted: each value gets a fresh random data key that
+// encrypts it with AES-256-GCM, and the data key itself is encrypted with the
+// active KEK. The stored form is
+//
+//	enc:<key version>:<base64 wrapped data key>:<base64 ciphertext>
+//
+// Older key versions stay in the keyring so existing values can still be
+// decrypted after a rotation.
+type Keyring struct {
+	active string            // Version used for new encryptions
+	keys   map[string][]byte // 32-byte AES keys by version
+}
+
+// NewKeyring parses a key specification of the form "v1:<base64 key>,v2:<base64 key>".
+// Keys must decode to 32 bytes. If active is empty, the last listed version is used.
+func NewKeyring(spec, active string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+
+	var last string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		version, encoded, ok := strings.Cut(entry, ":")
+		if !ok || version == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected <version>:<base64 key>", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not valid base64: %w", version, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes, got %d", version, len(key))
+		}
+		k.keys[version] = key
+		last = version
+	}
+
+	if len(k.keys) == 0 {
+		return nil, errors.New("no encryption keys configured")
+	}
+
+	if active == "" {
+		active = last
+	}
+	if _, ok := k.keys[active]; !ok {
+		return nil, fmt.Errorf("active key version %q is not in the keyring", active)
+	}
+	k.active = active
+	return k, nil
+}
+
+// LoadKeyringFromEnv builds a Keyring from TOKEN_ENCRYPTION_KEYS and the
+// optional TOKEN_ENCRYPTION_ACTIVE_KEY environment variables.
+func LoadKeyringFromEnv() (*Keyring, error) {
+	spec := os.Getenv("TOKEN_ENCRYPTION_KEYS")
+	if spec == "" {
+		return nil, errors.New("TOKEN_ENCRYPTION_KEYS is not set")
+	}
+	return NewKeyring(spec, os.Getenv("TOKEN_ENCRYPTION_ACTIVE_KEY"))
+}
+
+// Encrypt envelope-encrypts plaintext with the active key. Empty strings are returned unchanged.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(k.keys[k.active], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + k.active + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt reverses Encrypt. Values without the encrypted prefix are treated as
+// legacy plaintext and returned unchanged so they can be migrated.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	kek, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key version %q", parts[0])
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := open(kek, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencryption reports whether value is plaintext or was encrypted with a non-active key.
+func (k *Keyring) NeedsReencryption(value string) bool {
+	if value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, encryptedPrefix+k.active+":")
+}
+
+// IsEncrypted reports whether value was produced by Keyring.Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// seal encrypts data with AES-GCM under key and prepends the random nonce.
+func seal(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// open decrypts data produced by seal.
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	keyringMu sync.RWMutex
+	keyring   *Keyring
+)
+
+// SetKeyring installs the keyring used by EncryptString and DecryptString.
+// It must be called at startup before any encrypted value is read or written.
+func SetKeyring(k *Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	keyring = k
+}
+
+// CurrentKeyring returns the keyring installed with SetKeyring.
+func CurrentKeyring() (*Keyring, error) {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	if keyring == nil {
+		return nil, errors.New("token encryption keyring is not configured")
+	}
+	return keyring, nil
+}
+
+// EncryptString encrypts plaintext with the installed keyring.
+func EncryptString(plaintext string) (string, error) {
+	k, err := CurrentKeyring()
+	if err != nil {
+		return "", err
+	}
+	return k.Encrypt(plaintext)
+}
+
+// DecryptString decrypts value with the installed keyring.
+func DecryptString(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	k, err := CurrentKeyring()
+	if err != nil {
+		return "", err
+	}
+	return k.Decrypt(value)
+}
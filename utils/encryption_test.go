@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// testKey returns a base64 encoded 32-byte key filled with b.
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// mustKeyring builds a Keyring or fails the test.
+func mustKeyring(t *testing.T, spec, active string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(spec, active)
+	if err != nil {
+		t.Fatalf("NewKeyring(%q, %q): %v", spec, active, err)
+	}
+	return k
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       string
+		active     string
+		wantActive string
+		wantErr    bool
+	}{
+		{name: "single key", spec: "v1:" + testKey(1), wantActive: "v1"},
+		{name: "last key is active by default", spec: "v1:" + testKey(1) + ", v2:" + testKey(2), wantActive: "v2"},
+		{name: "explicit active key", spec: "v1:" + testKey(1) + ",v2:" + testKey(2), active: "v1", wantActive: "v1"},
+		{name: "unknown active key", spec: "v1:" + testKey(1), active: "v2", wantErr: true},
+		{name: "empty spec", spec: " , ", wantErr: true},
+		{name: "missing version", spec: ":" + testKey(1), wantErr: true},
+		{name: "missing separator", spec: testKey(1), wantErr: true},
+		{name: "invalid base64", spec: "v1:not base64!", wantErr: true},
+		{name: "short key", spec: "v1:" + base64.StdEncoding.EncodeToString([]byte("too short")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(tt.spec, tt.active)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeyring error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && k.active != tt.wantActive {
+				t.Errorf("active = %q, want %q", k.active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	k := mustKeyring(t, "v1:"+testKey(1), "")
+
+	for _, plaintext := range []string{"", "ya29.access-token", "1//refresh:token:with:colons", strings.Repeat("x", 4096)} {
+		encrypted, err := k.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt(%q): %v", plaintext, err)
+		}
+		if plaintext == "" {
+			if encrypted != "" {
+				t.Errorf("Encrypt(\"\") = %q, want empty", encrypted)
+			}
+			continue
+		}
+		if !strings.HasPrefix(encrypted, "enc:v1:") || strings.Contains(encrypted, plaintext) {
+			t.Errorf("Encrypt(%q) = %q, want an enc:v1: value hiding the plaintext", plaintext, encrypted)
+		}
+
+		decrypted, err := k.Decrypt(encrypted)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt = %q, want %q", decrypted, plaintext)
+		}
+	}
+
+	a, _ := k.Encrypt("same")
+	b, _ := k.Encrypt("same")
+	if a == b {
+		t.Error("encrypting the same value twice produced the same ciphertext")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old := mustKeyring(t, "v1:"+testKey(1), "")
+	encrypted, err := old.Encrypt("refresh-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	rotated := mustKeyring(t, "v1:"+testKey(1)+",v2:"+testKey(2), "")
+	decrypted, err := rotated.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt with rotated keyring: %v", err)
+	}
+	if decrypted != "refresh-token" {
+		t.Errorf("Decrypt = %q, want refresh-token", decrypted)
+	}
+
+	reencrypted, err := rotated.Encrypt(decrypted)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(reencrypted, "enc:v2:") {
+		t.Errorf("rotated keyring encrypted with %q, want v2", reencrypted)
+	}
+
+	// Once the old key is retired its values can no longer be read
+	retired := mustKeyring(t, "v2:"+testKey(2), "")
+	if _, err := retired.Decrypt(encrypted); err == nil {
+		t.Error("Decrypt succeeded without the key version the value was encrypted with")
+	}
+}
+
+func TestKeyringDecryptRejectsMalformed(t *testing.T) {
+	k := mustKeyring(t, "v1:"+testKey(1)+",v2:"+testKey(2), "v1")
+	valid, err := k.Encrypt("access-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	parts := strings.Split(strings.TrimPrefix(valid, encryptedPrefix), ":")
+	wrapped, ciphertext := parts[1], parts[2]
+
+	// flip corrupts one byte of a base64 segment
+	flip := func(segment string) string {
+		b, err := base64.RawStdEncoding.DecodeString(segment)
+		if err != nil {
+			t.Fatalf("invalid segment %q: %v", segment, err)
+		}
+		b[len(b)/2] ^= 0xff
+		return base64.RawStdEncoding.EncodeToString(b)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "missing segments", value: "enc:v1:" + wrapped},
+		{name: "extra segment", value: valid + ":extra"},
+		{name: "unknown key version", value: "enc:v9:" + wrapped + ":" + ciphertext},
+		{name: "data key wrapped under another version", value: "enc:v2:" + wrapped + ":" + ciphertext},
+		{name: "invalid wrapped key encoding", value: "enc:v1:!!!:" + ciphertext},
+		{name: "invalid ciphertext encoding", value: "enc:v1:" + wrapped + ":!!!"},
+		{name: "tampered wrapped key", value: "enc:v1:" + flip(wrapped) + ":" + ciphertext},
+		{name: "tampered ciphertext", value: "enc:v1:" + wrapped + ":" + flip(ciphertext)},
+		{name: "truncated ciphertext", value: "enc:v1:" + wrapped + ":AAAA"},
+		{name: "empty segments", value: "enc:v1::"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := k.Decrypt(tt.value); err == nil {
+				t.Errorf("Decrypt(%q) = %q, want an error", tt.value, got)
+			}
+		})
+	}
+}
+
+func TestKeyringDecryptPlaintext(t *testing.T) {
+	k := mustKeyring(t, "v1:"+testKey(1), "")
+	for _, value := range []string{"", "ya29.legacy-plaintext"} {
+		got, err := k.Decrypt(value)
+		if err != nil || got != value {
+			t.Errorf("Decrypt(%q) = %q, %v; want the value unchanged", value, got, err)
+		}
+	}
+}
+
+func TestNeedsReencryption(t *testing.T) {
+	old := mustKeyring(t, "v1:"+testKey(1), "")
+	k := mustKeyring(t, "v1:"+testKey(1)+",v2:"+testKey(2), "")
+	oldValue, _ := old.Encrypt("token")
+	activeValue, _ := k.Encrypt("token")
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "empty", value: "", want: false},
+		{name: "plaintext", value: "ya29.token", want: true},
+		{name: "old key version", value: oldValue, want: true},
+		{name: "active key version", value: activeValue, want: false},
+		{name: "version prefix of another version", value: "enc:v2x:a:b", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.NeedsReencryption(tt.value); got != tt.want {
+				t.Errorf("NeedsReencryption(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptStringWithoutKeyring(t *testing.T) {
+	SetKeyring(nil)
+	t.Cleanup(func() { SetKeyring(nil) })
+
+	if got, err := DecryptString("plaintext"); err != nil || got != "plaintext" {
+		t.Errorf("DecryptString(plaintext) = %q, %v; want the value unchanged", got, err)
+	}
+	if _, err := DecryptString("enc:v1:a:b"); err == nil {
+		t.Error("DecryptString succeeded without a keyring")
+	}
+	if _, err := EncryptString("token"); err == nil {
+		t.Error("EncryptString succeeded without a keyring")
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnv returns the value of the environment variable key, or fallback if it is unset or empty.
+func GetEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvDuration parses the environment variable key as a time.Duration (e.g. "24h").
+// It returns fallback if the variable is unset or cannot be parsed.
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("⚠️ Invalid duration %q for %s, using %s", value, key, fallback)
+		return fallback
+	}
+	return d
+}
+
+// GetEnvInt parses the environment variable key as an integer.
+// It returns fallback if the variable is unset or cannot be parsed.
+func GetEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid integer %q for %s, using %d", value, key, fallback)
+		return fallback
+	}
+	return n
+}
+
+// GetEnvBool parses the environment variable key as a boolean ("true", "1", ...).
+// It returns fallback if the variable is unset or cannot be parsed.
+func GetEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid boolean %q for %s, using %t", value, key, fallback)
+		return fallback
+	}
+	return b
+}
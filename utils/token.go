@@ -1,40 +1,68 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 )
 
-// secretKey stores the JWT signing secret, retrieved from environment variables.
-var secretKey = []byte(os.Getenv("SECRET_KEY"))
+// SessionClaims are the claims carried by an application-issued session token.
+// The subject is the user's UUID and SessionID identifies the login session.
+type SessionClaims struct {
+	SessionID string `json:"sid"` // Identifier of the session this token belongs to
+	jwt.StandardClaims
+}
+
+// UserID parses the token subject as a user UUID.
+func (c *SessionClaims) UserID() (uuid.UUID, error) {
+	return uuid.Parse(c.Subject)
+}
+
+// secretKey returns the JWT signing secret from the SECRET_KEY environment variable.
+// It is read on every call so that values loaded from .env at startup are honoured.
+func secretKey() ([]byte, error) {
+	key := os.Getenv("SECRET_KEY")
+	if key == "" {
+		return nil, errors.New("SECRET_KEY is not set")
+	}
+	return []byte(key), nil
+}
 
-// GenerateToken creates a JWT containing the given OAuth access token.
-// The token expires in 24 hours.
+// GenerateToken creates a signed session token for the given user and session.
 //
 // Parameters:
-//   - accessToken: The OAuth access token to embed in the JWT.
+//   - userID: The UUID of the authenticated user.
+//   - sessionID: The identifier of the login session.
+//   - ttl: How long the token remains valid.
 //
 // Returns:
 //   - A signed JWT as a string.
-func GenerateToken(accessToken string) (string, error) {
-	if len(secretKey) == 0 {
-		return "", errors.New("SECRET_KEY is not set")
+func GenerateToken(userID uuid.UUID, sessionID string, ttl time.Duration) (string, error) {
+	key, err := secretKey()
+	if err != nil {
+		return "", err
 	}
 
-	// Define JWT claims
-	claims := jwt.MapClaims{
-		"access_token": accessToken,
-		"exp":          time.Now().Add(24 * time.Hour).Unix(), // Token expires in 24 hours
+	now := time.Now()
+	claims := SessionClaims{
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   userID.String(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
 	}
 
-	// Create token with claims
+	// Create token with claims and sign it using the secret key
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign token using the secret key
-	tokenString, err := token.SignedString(secretKey)
+	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", err
 	}
@@ -42,36 +70,51 @@ func GenerateToken(accessToken string) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateToken verifies a JWT and extracts the access token.
+// ValidateToken verifies a session token's signature and expiry and returns its claims.
 //
 // Parameters:
 //   - tokenString: The JWT to validate.
 //
 // Returns:
-//   - The extracted access token if valid.
+//   - The session claims if the token is valid.
 //   - An error if the token is invalid or expired.
-func ValidateToken(tokenString string) (string, error) {
-	if len(secretKey) == 0 {
-		return "", errors.New("SECRET_KEY is not set")
+func ValidateToken(tokenString string) (*SessionClaims, error) {
+	key, err := secretKey()
+	if err != nil {
+		return nil, err
 	}
 
-	// Parse and validate the token
-	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
+	// Parse and validate the token, refusing anything not signed with HS256
+	token, err := jwt.ParseWithClaims(tokenString, &SessionClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return key, nil
 	})
-
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Extract and return the access token if valid
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		accessToken, ok := claims["access_token"].(string)
-		if !ok {
-			return "", errors.New("access_token not found in token claims")
-		}
-		return accessToken, nil
+	claims, ok := token.Claims.(*SessionClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if claims.Subject == "" || claims.SessionID == "" {
+		return nil, errors.New("token is missing subject or session ID")
 	}
 
-	return "", errors.New("invalid token")
+	return claims, nil
+}
+
+// CSRFToken returns the anti-CSRF token for a session. It is an HMAC of the session
+// ID under SECRET_KEY, so it cannot be forged without the key, changes with every
+// login and needs no server-side storage.
+func CSRFToken(sessionID string) (string, error) {
+	key, err := secretKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("csrf:" + sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
 }
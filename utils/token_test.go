@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+const testSecret = "test-secret-key"
+
+// signClaims signs claims with the given method and key, bypassing GenerateToken.
+func signClaims(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestValidateToken(t *testing.T) {
+	t.Setenv("SECRET_KEY", testSecret)
+	userID := uuid.New()
+	now := time.Now()
+
+	valid, err := GenerateToken(userID, "session-1", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "valid token",
+			token: valid,
+		},
+		{
+			name: "expired token",
+			token: signClaims(t, jwt.SigningMethodHS256, []byte(testSecret), SessionClaims{
+				SessionID:      "session-1",
+				StandardClaims: jwt.StandardClaims{Subject: userID.String(), IssuedAt: now.Add(-2 * time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix()},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "tampered payload",
+			token: parts[0] + "." + base64.RawURLEncoding.EncodeToString(
+				[]byte(`{"sid":"session-1","sub":"`+uuid.NewString()+`","exp":`+strings.Repeat("9", 10)+`}`)) + "." + parts[2],
+			wantErr: true,
+		},
+		{
+			name:    "tampered signature",
+			token:   parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])),
+			wantErr: true,
+		},
+		{
+			name: "signed with another key",
+			token: signClaims(t, jwt.SigningMethodHS256, []byte("another-key"), SessionClaims{
+				SessionID:      "session-1",
+				StandardClaims: jwt.StandardClaims{Subject: userID.String(), ExpiresAt: now.Add(time.Hour).Unix()},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "other HMAC algorithm",
+			token: signClaims(t, jwt.SigningMethodHS512, []byte(testSecret), SessionClaims{
+				SessionID:      "session-1",
+				StandardClaims: jwt.StandardClaims{Subject: userID.String(), ExpiresAt: now.Add(time.Hour).Unix()},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "unsigned token",
+			token: signClaims(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, SessionClaims{
+				SessionID:      "session-1",
+				StandardClaims: jwt.StandardClaims{Subject: userID.String(), ExpiresAt: now.Add(time.Hour).Unix()},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "missing session ID",
+			token: signClaims(t, jwt.SigningMethodHS256, []byte(testSecret), SessionClaims{
+				StandardClaims: jwt.StandardClaims{Subject: userID.String(), ExpiresAt: now.Add(time.Hour).Unix()},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "missing subject",
+			token: signClaims(t, jwt.SigningMethodHS256, []byte(testSecret), SessionClaims{
+				SessionID:      "session-1",
+				StandardClaims: jwt.StandardClaims{ExpiresAt: now.Add(time.Hour).Unix()},
+			}),
+			wantErr: true,
+		},
+		{
+			name:    "malformed token",
+			token:   "not-a-jwt",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ValidateToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateToken error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got, err := claims.UserID(); err != nil || got != userID {
+				t.Errorf("UserID = %v, %v; want %v", got, err, userID)
+			}
+			if claims.SessionID != "session-1" {
+				t.Errorf("SessionID = %q, want session-1", claims.SessionID)
+			}
+		})
+	}
+}
+
+func TestTokensRequireSecretKey(t *testing.T) {
+	t.Setenv("SECRET_KEY", testSecret)
+	token, err := GenerateToken(uuid.New(), "session-1", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	t.Setenv("SECRET_KEY", "")
+	if _, err := GenerateToken(uuid.New(), "session-1", time.Hour); err == nil {
+		t.Error("GenerateToken succeeded without SECRET_KEY")
+	}
+	if _, err := ValidateToken(token); err == nil {
+		t.Error("ValidateToken succeeded without SECRET_KEY")
+	}
+	if _, err := CSRFToken("session-1"); err == nil {
+		t.Error("CSRFToken succeeded without SECRET_KEY")
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	t.Setenv("SECRET_KEY", testSecret)
+	reference, err := CSRFToken("session-1")
+	if err != nil {
+		t.Fatalf("CSRFToken: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		sessionID string
+		wantSame  bool
+	}{
+		{name: "same session", secret: testSecret, sessionID: "session-1", wantSame: true},
+		{name: "other session", secret: testSecret, sessionID: "session-2"},
+		{name: "rotated secret", secret: "another-key", sessionID: "session-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SECRET_KEY", tt.secret)
+			got, err := CSRFToken(tt.sessionID)
+			if err != nil {
+				t.Fatalf("CSRFToken: %v", err)
+			}
+			if (got == reference) != tt.wantSame {
+				t.Errorf("CSRFToken = %q, reference %q, wantSame %v", got, reference, tt.wantSame)
+			}
+		})
+	}
+}